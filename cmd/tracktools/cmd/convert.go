@@ -6,8 +6,11 @@ import (
 	"os"
 	"time"
 
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/stevenh/tracktools/pkg/convert"
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf"
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf/geo"
 	"github.com/stevenh/tracktools/pkg/laptimer"
 	"github.com/stevenh/tracktools/pkg/trackaddict"
 )
@@ -29,6 +32,14 @@ type convertCmd struct {
 	Tags      []string
 	Note      string
 	StartDate date
+	HiLights  []string
+	Sectors   []Start
+	Mode      string
+	Start     Start
+	Finish    Start
+
+	HeadingTolerance float64
+	AnyDirection     bool
 }
 
 func (c *convertCmd) RunE(cmd *cobra.Command, args []string) (err error) { //nolint: nonamedreturns
@@ -49,6 +60,23 @@ func (c *convertCmd) RunE(cmd *cobra.Command, args []string) (err error) { //nol
 		return fmt.Errorf("convert: unknown encoder: %q", c.Encoder)
 	}
 
+	// No mode, for example from an older config, is a circuit.
+	if c.Mode == "" {
+		c.Mode = modeCircuit
+	}
+
+	switch c.Mode {
+	case modeCircuit:
+	case modeStage:
+		if c.Start.Latitude == 0 && c.Start.Longitude == 0 {
+			return fmt.Errorf("convert: %s mode requires a start line", c.Mode)
+		} else if c.Finish.Latitude == 0 && c.Finish.Longitude == 0 {
+			return fmt.Errorf("convert: %s mode requires a finish line", c.Mode)
+		}
+	default:
+		return fmt.Errorf("convert: unknown mode: %q", c.Mode)
+	}
+
 	// Open input / output if needed.
 	var input io.Reader
 	switch args[0] {
@@ -104,6 +132,14 @@ func (c *convertCmd) trackAddict2LapTimer(r io.Reader, w io.Writer) error {
 		convert.TagsOpt(c.Tags...),
 		convert.NoteOpt(c.Note),
 		convert.StartDateOpt(time.Time(c.StartDate)),
+		convert.SectorsOpt(sectorLines(c.Sectors)...),
+		convert.HeadingToleranceOpt(c.HeadingTolerance),
+	}
+	if c.AnyDirection {
+		taOpts = append(taOpts, convert.AnyDirectionOpt())
+	}
+	if c.Mode == modeStage {
+		taOpts = append(taOpts, convert.PointToPointOpt(c.Start.line(), c.Finish.line()))
 	}
 	ta, err := convert.NewTrackAddict(taOpts...)
 	if err != nil {
@@ -115,6 +151,10 @@ func (c *convertCmd) trackAddict2LapTimer(r io.Reader, w io.Writer) error {
 		return fmt.Errorf("convert: laptimer: %w", err)
 	}
 
+	if err = c.addHiLights(db); err != nil {
+		return err
+	}
+
 	encOpts := []laptimer.EncoderOpt{}
 	if c.Compress {
 		encOpts = append(encOpts, laptimer.Compress())
@@ -131,15 +171,55 @@ func (c *convertCmd) trackAddict2LapTimer(r io.Reader, w io.Writer) error {
 	return nil
 }
 
+// addHiLights adds the HiLight tags of the GoPro videos c.HiLights
+// to the notes of the laps in db which they occurred during.
+func (c *convertCmd) addHiLights(db *laptimer.DB) error {
+	if len(c.HiLights) == 0 {
+		return nil
+	}
+
+	groups, err := recordings(c.HiLights)
+	if err != nil {
+		return fmt.Errorf("convert: hilights: %w", err)
+	}
+
+	dec := gpmf.NewDecoder()
+	var marks []time.Time
+	for _, files := range groups {
+		times, err := hiLightTimes(dec, files...)
+		if err != nil {
+			return fmt.Errorf("convert: %w", err)
+		}
+		marks = append(marks, times...)
+	}
+
+	added := db.AddMarkers("HiLight", marks...)
+	log.Info().Int("tags", len(marks)).Int("added", added).Msg("HiLights")
+
+	return nil
+}
+
 // addConvertCmd adds the convert command.
 func addConvertCmd() {
 	c := convertCmd{}
 	cmd := &cobra.Command{
 		Use:   "convert input-file output-file",
 		Short: "Convert between track app formats.",
-		Long:  `Convert between different track app logging formats`,
-		Args:  cobra.ExactArgs(2),
-		RunE:  c.RunE,
+		Long: `Convert between different track app logging formats.
+
+When converting to LapTimer the intermediates of each lap are calculated
+from the crossings of the sector lines configured by Sectors, each defined
+the same as the gopro laptimes Start.
+
+With --mode stage the laps of the input are replaced by point to point runs
+timed from the Start line to the Finish line. Runs which reach the finish
+line are recorded as triggered and a last run which doesn't as incomplete.
+
+Only line crossings where the direction of travel is within
+--heading-tolerance degrees of the line's bearing count. Use
+--any-direction to count crossings in either direction.`,
+		Args: cobra.ExactArgs(2),
+		RunE: c.RunE,
 	}
 
 	fs := cmd.Flags()
@@ -151,6 +231,10 @@ func addConvertCmd() {
 	fs.StringVar(&c.Note, "note", "", "Override Note for the output")
 	fs.BoolVar(&c.Compress, "compress", false, "Override Compress option for output")
 	fs.Var(&c.StartDate, "start-date", "Override StartDate option for output (format YYYY-MM-DD)")
+	fs.StringArrayVar(&c.HiLights, "hilights", nil, "GoPro videos whose HiLight tags are added to the Note of laps")
+	fs.StringVar(&c.Mode, "mode", "", "Override timing Mode: circuit or stage")
+	fs.Float64Var(&c.HeadingTolerance, "heading-tolerance", geo.DefaultHeadingTolerance, "Override degrees the direction of travel can differ from a line bearing")
+	fs.BoolVar(&c.AnyDirection, "any-direction", false, "Override to count line crossings in either direction")
 	annotate(fs, "convert")
 
 	rootCmd.AddCommand(cmd)
@@ -49,6 +49,7 @@ func addGoproConvert() {
 	fs := cmd.Flags()
 	fs.StringVar(&c.cfg.SourceDir, "source-dir", "", "override source directory")
 	fs.StringVar(&c.cfg.OutputDir, "output-dir", "", "override output directory")
+	fs.BoolVar(&c.cfg.HiLightChapters, "hilight-chapters", false, "override adding HiLight tags as chapters")
 	annotate(fs, "gopro.convert")
 
 	goproCmd.AddCommand(cmd)
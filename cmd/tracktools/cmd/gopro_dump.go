@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf"
+)
+
+// Dump formats.
+const (
+	dumpSummary = "summary"
+	dumpJSON    = "json"
+	dumpCSV     = "csv"
+)
+
+// goproDumpCmd represents the gopro dump command.
+type goproDumpCmd struct {
+	Format          string
+	Key             string
+	PayloadDuration time.Duration
+	GapThreshold    time.Duration
+	Lenient         bool
+}
+
+func (c *goproDumpCmd) RunE(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(cmd, c); err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case dumpSummary, dumpJSON:
+	case dumpCSV:
+		if len(c.Key) != 4 {
+			return fmt.Errorf("dump: csv requires a FourCC key, got %q", c.Key)
+		}
+	default:
+		return fmt.Errorf("dump: unknown format: %q", c.Format)
+	}
+
+	groups, err := recordings(args)
+	if err != nil {
+		return fmt.Errorf("dump: %w", err)
+	}
+
+	var opts []gpmf.DecoderOption
+	if c.Lenient {
+		opts = append(opts, lenientDecoding())
+	}
+
+	dec := gpmf.NewDecoder(opts...)
+	w := cmd.OutOrStdout()
+	for _, files := range groups {
+		if err := c.process(w, dec, files...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// process writes the dump of files, which are either the chapters
+// of a single recording or a single raw GPMF file, to w.
+func (c *goproDumpCmd) process(w io.Writer, dec *gpmf.Decoder, files ...string) error {
+	var data []*gpmf.Element
+	var fn gpmf.PayloadFunc
+	var results func() error
+	switch c.Format {
+	case dumpSummary:
+		s := gpmf.NewStatsDumper(gpmf.GapThreshold(c.GapThreshold))
+		fn = s.Payload
+		results = func() error {
+			if _, err := fmt.Fprintf(w, "%s:\n", strings.Join(files, ", ")); err != nil {
+				return fmt.Errorf("write: %w", err)
+			}
+			return s.Results(w)
+		}
+	case dumpJSON:
+		fn = func(p *gpmf.Payload) error {
+			data = append(data, p.Elements...)
+			return nil
+		}
+		results = func() error {
+			return gpmf.Dump(w, data)
+		}
+	case dumpCSV:
+		d := gpmf.NewCSVDumper(w, c.Key)
+		fn = func(p *gpmf.Payload) error {
+			return gpmf.Walk(p.Elements, d.Walk)
+		}
+		results = d.Flush
+	}
+
+	if err := c.decode(dec, fn, files...); err != nil {
+		return fmt.Errorf("dump: decode %q: %w", files, err)
+	}
+
+	if err := results(); err != nil {
+		return fmt.Errorf("dump: %q: %w", files, err)
+	}
+
+	return nil
+}
+
+// decode decodes files calling fn for each payload. A single file
+// which isn't a mp4 is decoded as raw GPMF.
+func (c *goproDumpCmd) decode(dec *gpmf.Decoder, fn gpmf.PayloadFunc, files ...string) error {
+	if len(files) > 1 {
+		return dec.DecodeFiles(fn, files...)
+	}
+
+	f, err := os.Open(files[0]) //nolint: gosec // Yes it is.
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint: errcheck
+
+	mp4, err := isMP4(f)
+	if err != nil {
+		return err
+	}
+
+	if mp4 {
+		return dec.DecodePayloads(f, fn)
+	}
+
+	return dec.DecodeRaw(f, c.PayloadDuration, fn)
+}
+
+// isMP4 returns true if rs contains a mp4, leaving it positioned at the start.
+func isMP4(rs io.ReadSeeker) (bool, error) {
+	hdr := make([]byte, 8)
+	if _, err := io.ReadFull(rs, hdr); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, fmt.Errorf("read header: %w", err)
+	}
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return false, fmt.Errorf("seek: %w", err)
+	}
+
+	return bytes.Equal(hdr[4:], []byte("ftyp")), nil
+}
+
+func addGoproDump() {
+	c := goproDumpCmd{}
+	cmd := &cobra.Command{
+		Use:   "dump [file1] ... [fileN]",
+		Short: "Dump outputs the metadata of GoPro videos",
+		Long: `Dump outputs the GPMF metadata of GoPro videos or raw GPMF files.
+
+Formats:
+  summary - per stream sample counts, nominal and actual rate, units, time
+            span, gaps, dropped, duplicated and missing payloads followed by
+            the gaps and the GPS fix and dilution of precision over time.
+  json    - the full element tree.
+  csv     - the samples of the stream selected by --key, one per row.
+
+Chapters of the same recording are processed as one continuous recording.
+Raw GPMF files have no timing information so each payload is assumed to
+last --payload-duration. Gaps are intervals between samples longer than
+--gap-threshold, or double the expected interval of the stream if not set.
+
+With --lenient corrupt or truncated chunks of mp4 files are skipped with
+a warning instead of stopping processing.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: c.RunE,
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&c.Format, "format", dumpSummary, "output format: summary, json or csv")
+	fs.StringVar(&c.Key, "key", "", "FourCC of the stream to output for csv e.g. GPS5")
+	fs.DurationVar(&c.PayloadDuration, "payload-duration", time.Second, "duration of each payload of raw GPMF files")
+	fs.DurationVar(&c.GapThreshold, "gap-threshold", 0, "minimum interval between samples reported as a gap, 0 for double the expected interval")
+	fs.BoolVar(&c.Lenient, "lenient", false, "skip corrupt metadata instead of failing")
+	annotate(fs, "gopro.dump")
+
+	goproCmd.AddCommand(cmd)
+}
+
+func init() { //nolint: gochecknoinits
+	addGoproDump()
+}
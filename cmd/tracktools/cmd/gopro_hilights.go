@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf"
+)
+
+// goproHiLightsCmd represents the gopro hilights command.
+type goproHiLightsCmd struct {
+	UTC bool
+}
+
+func (c *goproHiLightsCmd) RunE(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(cmd, c); err != nil {
+		return err
+	}
+
+	groups, err := recordings(args)
+	if err != nil {
+		return fmt.Errorf("hilights: %w", err)
+	}
+
+	dec := gpmf.NewDecoder()
+	w := cmd.OutOrStdout()
+	for _, files := range groups {
+		if err := c.process(w, dec, files...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// process writes the HiLight tags of files, which are the chapters
+// of a single recording, to w.
+func (c *goproHiLightsCmd) process(w io.Writer, dec *gpmf.Decoder, files ...string) error {
+	hl, err := gpmf.DecodeHiLightFiles(files...)
+	if err != nil {
+		return fmt.Errorf("hilights: decode %q: %w", files, err)
+	}
+
+	var gps gpmf.GPSData
+	if c.UTC && len(hl.Tags) > 0 {
+		if gps, err = decodeGPS(dec, files...); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "%s: %d HiLights\n", strings.Join(files, ", "), len(hl.Tags)); err != nil {
+		return fmt.Errorf("hilights: write: %w", err)
+	}
+
+	for i, v := range hl.Tags {
+		line := fmt.Sprintf("  %d\t%s", i+1, formatOffset(v))
+		if t, ok := gps.TimeAt(v); ok {
+			line += "\t" + t.UTC().Format(time.RFC3339Nano)
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("hilights: write: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// decodeGPS returns the GPS samples of files, which are the chapters
+// of a single recording.
+func decodeGPS(dec *gpmf.Decoder, files ...string) (gpmf.GPSData, error) {
+	var gps gpmf.GPSData
+	if err := dec.DecodeFiles(func(p *gpmf.Payload) error {
+		gps = append(gps, gpmf.GPSSamples(p.Elements)...)
+		return nil
+	}, files...); err != nil {
+		return nil, fmt.Errorf("gps: decode %q: %w", files, err)
+	}
+
+	return gps, nil
+}
+
+// hiLightTimes returns the UTC times of the HiLight tags of files,
+// which are the chapters of a single recording, as determined from
+// the GPS data. Tags without a GPS time are skipped.
+func hiLightTimes(dec *gpmf.Decoder, files ...string) ([]time.Time, error) {
+	hl, err := gpmf.DecodeHiLightFiles(files...)
+	if err != nil {
+		return nil, fmt.Errorf("hilights: decode %q: %w", files, err)
+	}
+
+	if len(hl.Tags) == 0 {
+		return nil, nil
+	}
+
+	gps, err := decodeGPS(dec, files...)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make([]time.Time, 0, len(hl.Tags))
+	for _, v := range hl.Tags {
+		if t, ok := gps.TimeAt(v); ok {
+			times = append(times, t)
+		}
+	}
+
+	return times, nil
+}
+
+// formatOffset returns d formatted as hours, minutes, seconds and milliseconds.
+func formatOffset(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		d/time.Hour,
+		d%time.Hour/time.Minute,
+		d%time.Minute/time.Second,
+		d%time.Second/time.Millisecond,
+	)
+}
+
+func addGoproHiLights() {
+	c := goproHiLightsCmd{}
+	cmd := &cobra.Command{
+		Use:   "hilights [file1] ... [fileN]",
+		Short: "HiLights lists the HiLight tags of GoPro videos",
+		Long: `HiLights lists the HiLight tags of GoPro videos, added by pressing
+the HiLight button while recording, as offsets from the start of the recording.
+
+Chapters of the same recording are processed as one continuous recording.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: c.RunE,
+	}
+
+	fs := cmd.Flags()
+	fs.BoolVar(&c.UTC, "utc", false, "include the UTC time of each tag from the GPS data")
+	annotate(fs, "gopro.hilights")
+
+	goproCmd.AddCommand(cmd)
+}
+
+func init() { //nolint: gochecknoinits
+	addGoproHiLights()
+}
@@ -1,8 +1,15 @@
 package cmd
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
-	"os"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -10,13 +17,76 @@ import (
 	"github.com/stevenh/tracktools/pkg/gopro/gpmf/geo"
 )
 
+// Lap time formats.
+const (
+	lapsText = "text"
+	lapsJSON = "json"
+	lapsCSV  = "csv"
+)
+
+// Timing modes.
+const (
+	modeCircuit = "circuit"
+	modeStage   = "stage"
+)
+
+// msToKmh is the conversion factor from m/s to km/h.
+const msToKmh = 3.6
+
+// maxFixGap is the maximum time between consecutive GPS fixes across
+// which line crossings are detected.
+const maxFixGap = time.Second
+
 // goproLapTimesCmd represents the gopro laptimes command.
 type goproLapTimesCmd struct {
-	Start     Start
-	Tolerance float64
+	Mode       string
+	Start      Start
+	Finish     Start
+	Sectors    []Start
+	Tolerance  float64
+	Workers    int
+	Lenient    bool
+	Format     string
+	MinLapTime time.Duration
+
+	HeadingTolerance float64
+	AnyDirection     bool
+
+	p       *geo.Processor
+	timer   *geo.Timer
+	prev    gpmf.GPS
+	hasPrev bool
+	results []lapResult
+}
+
+// lapResult represents the laps of a recording.
+type lapResult struct {
+	Files           []string    `json:"files"`
+	Mode            string      `json:"mode"`
+	Laps            []lapRow    `json:"laps"`
+	Best            *float64    `json:"best,omitempty"`
+	TheoreticalBest *float64    `json:"theoretical_best,omitempty"`
+	Rolling         *rollingRow `json:"best_rolling,omitempty"`
+}
+
+// lapRow represents a lap for output.
+type lapRow struct {
+	Lap      int       `json:"lap"`
+	Type     string    `json:"type"`
+	Start    float64   `json:"start"`
+	Time     float64   `json:"time"`
+	Delta    *float64  `json:"delta,omitempty"`
+	MaxSpeed float64   `json:"max_speed_kmh"`
+	Distance float64   `json:"distance_m"`
+	Splits   []float64 `json:"splits,omitempty"`
+	Sectors  []float64 `json:"sectors,omitempty"`
+}
 
-	p     *geo.Processor
-	found int
+// rollingRow represents a rolling lap for output.
+type rollingRow struct {
+	Line  int     `json:"line"`
+	Start float64 `json:"start"`
+	Time  float64 `json:"time"`
 }
 
 func (c *goproLapTimesCmd) RunE(cmd *cobra.Command, args []string) error {
@@ -24,67 +94,394 @@ func (c *goproLapTimesCmd) RunE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	c.Start.calculate()
+	switch c.Format {
+	case lapsText, lapsJSON, lapsCSV:
+	default:
+		return fmt.Errorf("laptimes: unknown format: %q", c.Format)
+	}
+
+	// No mode, for example from an older config, is a circuit.
+	if c.Mode == "" {
+		c.Mode = modeCircuit
+	}
+
+	switch c.Mode {
+	case modeCircuit:
+	case modeStage:
+		if c.Start.Latitude == 0 && c.Start.Longitude == 0 {
+			return fmt.Errorf("laptimes: %s mode requires a start line", c.Mode)
+		} else if c.Finish.Latitude == 0 && c.Finish.Longitude == 0 {
+			return fmt.Errorf("laptimes: %s mode requires a finish line", c.Mode)
+		}
+	default:
+		return fmt.Errorf("laptimes: unknown mode: %q", c.Mode)
+	}
+
 	c.p = geo.NewProcessor(geo.Tolerance(c.Tolerance))
 
-	dec := &gpmf.Decoder{}
-	for _, fn := range args {
-		if err := c.process(dec, fn); err != nil {
+	groups, err := recordings(args)
+	if err != nil {
+		return fmt.Errorf("laptimes: %w", err)
+	}
+
+	if c.Workers <= 0 {
+		c.Workers = runtime.NumCPU()
+	}
+
+	opts := []gpmf.DecoderOption{gpmf.Workers(c.Workers)}
+	if c.Lenient {
+		opts = append(opts, lenientDecoding())
+	}
+
+	dec := gpmf.NewDecoder(opts...)
+	for _, files := range groups {
+		if err := c.process(dec, files...); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return c.write(cmd.OutOrStdout())
 }
 
-func (c *goproLapTimesCmd) process(dec *gpmf.Decoder, file string) error {
-	f, err := os.Open(file) //nolint: gosec // Yes it is.
-	if err != nil {
-		return fmt.Errorf("laptimes: open %w", err)
+// process processes files which are the chapters of a single recording.
+func (c *goproLapTimesCmd) process(dec *gpmf.Decoder, files ...string) error {
+	opts := []geo.TimerOption{
+		geo.MinLapTime(c.MinLapTime),
+		geo.Sectors(sectorLines(c.Sectors)...),
+		geo.HeadingTolerance(c.HeadingTolerance),
+	}
+	if c.AnyDirection {
+		opts = append(opts, geo.AnyDirection())
+	}
+	if c.Mode == modeStage {
+		opts = append(opts, geo.Finish(c.Finish.line()))
+	}
+	c.timer = geo.NewTimer(c.p, c.Start.line(), opts...)
+	c.prev, c.hasPrev = gpmf.GPS{}, false
+	stats := gpmf.NewStatsDumper()
+	if err := dec.DecodeFiles(func(p *gpmf.Payload) error {
+		c.check(gpmf.GPSSamples(p.Elements))
+		return stats.Payload(p)
+	}, files...); err != nil {
+		return fmt.Errorf("laptimes: decode %q: %w", files, err)
 	}
 
-	defer f.Close() //nolint: errcheck
+	laps := c.timer.Laps()
+	if len(laps) == 0 {
+		return fmt.Errorf("laptimes: walk %q: no laps found", files)
+	}
 
-	data, err := dec.Decode(f)
-	if err != nil {
-		return fmt.Errorf("laptimes: decode %q: %w", file, err)
+	if gps := stats.GPS(); gps != nil {
+		checkGaps(laps, gps.Gaps)
 	}
 
-	if err := gpmf.Walk(data, c.walk); err != nil {
-		return fmt.Errorf("laptimes: walk %q: %w", file, err)
+	res := lapResult{Files: files, Mode: c.Mode}
+	for _, l := range laps {
+		row := lapRow{
+			Lap:      l.Number,
+			Type:     l.Type.String(),
+			Start:    l.Start.Seconds(),
+			Time:     l.Time.Seconds(),
+			MaxSpeed: l.MaxSpeed * msToKmh,
+			Distance: l.Distance,
+		}
+		if l.Type.Timed() {
+			delta := l.Delta.Seconds()
+			row.Delta = &delta
+		}
+		for _, v := range l.Splits {
+			row.Splits = append(row.Splits, v.Time.Seconds())
+		}
+		for _, v := range l.Sectors {
+			row.Sectors = append(row.Sectors, v.Seconds())
+		}
+		res.Laps = append(res.Laps, row)
 	}
 
-	if c.found == 0 {
-		return fmt.Errorf("laptimes: walk %q: no laps found", file)
+	if best, ok := geo.Best(laps); ok {
+		v := best.Time.Seconds()
+		res.Best = &v
 	}
 
+	if best, ok := c.timer.TheoreticalBest(); ok {
+		v := best.Seconds()
+		res.TheoreticalBest = &v
+	}
+
+	if best, ok := c.timer.BestRolling(); ok {
+		res.Rolling = &rollingRow{
+			Line:  best.Line,
+			Start: best.Start.Seconds(),
+			Time:  best.Time.Seconds(),
+		}
+	}
+	c.results = append(c.results, res)
+
 	return nil
 }
 
-func (c *goproLapTimesCmd) walk(e *gpmf.Element) error {
-	data, ok := e.Data.(gpmf.GPSData)
-	if !ok {
-		return nil
+// checkGaps warns about laps which contain GPS gaps, as their
+// times may be inaccurate.
+func checkGaps(laps []geo.Lap, gaps []gpmf.Gap) {
+	for _, l := range laps {
+		for _, g := range gaps {
+			if g.Overlaps(l.Start, l.End) {
+				log.Warn().
+					Int("lap", l.Number).
+					Str("gap_start", g.Start.String()).
+					Str("gap_end", g.End.String()).
+					Str("gap", g.Duration().String()).
+					Msg("lap contains a GPS gap, time may be inaccurate")
+			}
+		}
 	}
+}
 
+// check adds data to the lap timer, logging each start line crossing.
+// Fixes without a 2D or 3D lock are skipped, and crossings aren't
+// detected across them or gaps of more than maxFixGap.
+func (c *goproLapTimesCmd) check(data gpmf.GPSData) {
 	for _, v := range data {
-		if c.p.OnLine(v.Latitude, v.Longitude, c.Start.lat1, c.Start.lon1, c.Start.lat2, c.Start.lon2) {
-			log.Info().Object("gps", v).Msg("start line passed")
-			c.found++
+		if v.Fix < gpmf.GPS2DLock {
+			c.timer.Reset()
+			continue
+		}
+
+		prev, hasPrev := c.prev, c.hasPrev
+		c.prev, c.hasPrev = v, true
+		if hasPrev && v.Offset-prev.Offset > maxFixGap {
+			c.timer.Reset()
+		}
+
+		cr, ok := c.timer.Add(geo.Fix{
+			Latitude:  v.Latitude,
+			Longitude: v.Longitude,
+			Speed:     v.Speed,
+			Offset:    v.Offset,
+		})
+		if !ok {
+			continue
+		}
+
+		ev := log.Debug().
+			Float64("latitude", cr.Latitude).
+			Float64("longitude", cr.Longitude).
+			Str("offset", cr.Offset.String())
+		if !prev.Time.IsZero() {
+			ev = ev.Time("time", prev.Time.Add(cr.Offset-prev.Offset))
+		}
+		ev.Msg("start line passed")
+	}
+}
+
+// write writes the results to w in the configured format.
+func (c *goproLapTimesCmd) write(w io.Writer) error {
+	switch c.Format {
+	case lapsJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(c.results); err != nil {
+			return fmt.Errorf("laptimes: json encode: %w", err)
+		}
+	case lapsCSV:
+		cw := csv.NewWriter(w)
+		header := []string{"recording", "lap", "type", "start", "time", "delta", "max_speed_kmh", "distance_m"}
+		for i := range c.sectors() {
+			header = append(header, fmt.Sprintf("sector%d", i+1))
+		}
+		cw.Write(header) //nolint: errcheck
+		for _, res := range c.results {
+			for _, l := range res.Laps {
+				var delta string
+				if l.Delta != nil {
+					delta = formatFloat(*l.Delta, 3)
+				}
+				row := []string{
+					res.Files[0],
+					strconv.Itoa(l.Lap),
+					l.Type,
+					formatFloat(l.Start, 3),
+					formatFloat(l.Time, 3),
+					delta,
+					formatFloat(l.MaxSpeed, 1),
+					formatFloat(l.Distance, 1),
+				}
+				for i := range c.sectors() {
+					var v string
+					if i < len(l.Sectors) {
+						v = formatFloat(l.Sectors[i], 3)
+					}
+					row = append(row, v)
+				}
+				cw.Write(row) //nolint: errcheck
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("laptimes: csv: %w", err)
+		}
+	default:
+		for i, res := range c.results {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			if err := writeLapTable(w, res, c.sectors()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sectors returns the number of sectors of each lap, zero if there
+// are no sector lines.
+func (c *goproLapTimesCmd) sectors() int {
+	if len(c.Sectors) == 0 {
+		return 0
+	}
+
+	return len(c.Sectors) + 1
+}
+
+// writeLapTable writes the laps of res to w as a table followed by
+// the best, theoretical best and best rolling lap times.
+func writeLapTable(w io.Writer, res lapResult, sectors int) error {
+	if _, err := fmt.Fprintf(w, "%s:\n", strings.Join(res.Files, ", ")); err != nil {
+		return fmt.Errorf("laptimes: write: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := "LAP\tTYPE\tTIME\tDELTA\tMAX KM/H\tDISTANCE M\t"
+	for i := range sectors {
+		header += fmt.Sprintf("S%d\t", i+1)
+	}
+	if _, err := fmt.Fprintln(tw, header); err != nil {
+		return fmt.Errorf("laptimes: write: %w", err)
+	}
+
+	for _, l := range res.Laps {
+		delta := "-"
+		if l.Delta != nil {
+			delta = "+" + formatFloat(*l.Delta, 3)
+		}
+		line := fmt.Sprintf("%d\t%s\t%s\t%s\t%.1f\t%.0f\t",
+			l.Lap,
+			l.Type,
+			formatLapTime(seconds(l.Time)),
+			delta,
+			l.MaxSpeed,
+			l.Distance,
+		)
+		for i := range sectors {
+			v := "-"
+			if i < len(l.Sectors) {
+				v = formatFloat(l.Sectors[i], 3)
+			}
+			line += v + "\t"
+		}
+
+		if _, err := fmt.Fprintln(tw, line); err != nil {
+			return fmt.Errorf("laptimes: write: %w", err)
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("laptimes: write: %w", err)
+	}
+
+	var summary []string
+	if res.Best != nil {
+		label := "Best lap:"
+		if res.Mode == modeStage {
+			label = "Best run:"
+		}
+		summary = append(summary, fmt.Sprintf("%-19s%s", label, formatLapTime(seconds(*res.Best))))
+	}
+	if res.TheoreticalBest != nil {
+		summary = append(summary, "Theoretical best:  "+formatLapTime(seconds(*res.TheoreticalBest)))
+	}
+	if r := res.Rolling; r != nil {
+		from := "start line"
+		if r.Line > 0 {
+			from = fmt.Sprintf("sector line %d", r.Line)
+		}
+		summary = append(summary, fmt.Sprintf("Best rolling lap:  %s from %s at %s",
+			formatLapTime(seconds(r.Time)),
+			from,
+			formatLapTime(seconds(r.Start)),
+		))
+	}
+
+	for _, line := range summary {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("laptimes: write: %w", err)
 		}
 	}
 
 	return nil
 }
 
+// formatLapTime returns d formatted as a lap time, for example 1:23.456.
+func formatLapTime(d time.Duration) string {
+	d = d.Round(time.Millisecond)
+	return fmt.Sprintf("%d:%02d.%03d",
+		d/time.Minute,
+		d%time.Minute/time.Second,
+		d%time.Second/time.Millisecond,
+	)
+}
+
+// formatFloat returns v formatted with prec decimal places.
+func formatFloat(v float64, prec int) string {
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
+
+// seconds returns v seconds as a Duration.
+func seconds(v float64) time.Duration {
+	return time.Duration(v * float64(time.Second))
+}
+
 func addGoproLapTimes() {
 	c := goproLapTimesCmd{}
 	cmd := &cobra.Command{
 		Use:   "laptimes [file1] ... [fileN]",
 		Short: "LapTimes reports laptimes of GoPro videos",
-		Long:  `LapTimes reports laptimes of GoPro based on the GPS metadata information.`,
-		Args:  cobra.MinimumNArgs(1),
-		RunE:  c.RunE,
+		Long: `LapTimes reports laptimes of GoPro based on the GPS metadata information.
+
+Start line crossings are detected between consecutive GPS fixes with the
+time of each interpolated to the point the line is crossed. The laps are
+output with their time, delta to the best lap, maximum speed and distance.
+If sector lines are configured by Sectors, each defined the same as Start,
+the time of each sector is also output, along with the theoretical best
+lap made up of the best time for each sector. The best rolling lap is the
+fastest lap starting and ending at any of the timing lines.
+The partial laps before the first and after the last crossing are marked
+as the out and in laps. Crossings less than --min-lap-time after the
+previous crossing are ignored.
+
+Only crossings where the direction of travel is within --heading-tolerance
+degrees of the line's bearing count, so crossing the start line backwards
+or travelling almost parallel to it, for example in the paddock or on a pit
+road, doesn't result in extra laps. Use --any-direction to count crossings
+in either direction.
+
+With --mode stage runs are timed point to point from the start line to a
+separate finish line, configured by Finish the same as Start, for example
+for hillclimbs, sprints or rally stages. Each run is output as a run with
+a run which doesn't reach the finish line output as an in lap.
+
+Chapters of the same recording are processed as one continuous recording.
+GPS fixes without a 2D or 3D lock are ignored and line crossings aren't
+detected across them or gaps of more than a second. A warning is logged
+for each lap which contains a gap in the GPS data, as its time may be
+inaccurate.
+
+With --lenient corrupt or truncated metadata, such as the last chapter of a
+recording interrupted by a crash, is skipped with a warning instead of
+stopping processing.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: c.RunE,
 	}
 
 	fs := cmd.Flags()
@@ -92,8 +489,23 @@ func addGoproLapTimes() {
 	fs.Float64Var(&c.Start.Longitude, "longitude", 0, "override start longitude")
 	fs.Float64Var(&c.Start.Bearing, "bearing", 0, "override start bearing")
 	fs.Float64Var(&c.Start.Distance, "distance", 0, "override start distance")
-	fs.Float64Var(&c.Tolerance, "tolerance", 0, "override tolerance")
+	fs.Float64Var(&c.Tolerance, "tolerance", 0, "override distance in metres the start line is extended at each end")
+	fs.IntVar(&c.Workers, "workers", 0, "number of concurrent metadata readers, 0 for one per CPU")
+	fs.BoolVar(&c.Lenient, "lenient", false, "skip corrupt metadata instead of failing")
+	fs.StringVar(&c.Format, "format", lapsText, "output format: text, json or csv")
+	fs.DurationVar(&c.MinLapTime, "min-lap-time", 10*time.Second, "minimum time between start line crossings")
+	fs.Float64Var(&c.HeadingTolerance, "heading-tolerance", geo.DefaultHeadingTolerance, "degrees the direction of travel can differ from a line bearing")
+	fs.BoolVar(&c.AnyDirection, "any-direction", false, "count line crossings in either direction")
+	fs.StringVar(&c.Mode, "mode", modeCircuit, "timing mode: circuit or stage")
+	fs.Float64Var(&c.Finish.Latitude, "finish-latitude", 0, "override finish latitude for stage mode")
+	fs.Float64Var(&c.Finish.Longitude, "finish-longitude", 0, "override finish longitude for stage mode")
+	fs.Float64Var(&c.Finish.Bearing, "finish-bearing", 0, "override finish bearing for stage mode")
+	fs.Float64Var(&c.Finish.Distance, "finish-distance", 0, "override finish distance for stage mode")
 	annotate(fs, "gopro.laptimes")
+	for _, v := range []string{"latitude", "longitude", "bearing", "distance"} {
+		configKey(fs, v, "start."+v)
+		configKey(fs, "finish-"+v, "finish."+v)
+	}
 
 	goproCmd.AddCommand(cmd)
 }
@@ -37,13 +37,11 @@ func (c *goproRenderCmd) RunE(cmd *cobra.Command, args []string) error {
 
 	defer f.Close() //nolint: errcheck
 
-	data, err := dec.Decode(f)
-	if err != nil {
+	if err := dec.DecodePayloads(f, func(p *gpmf.Payload) error {
+		c.add(gpmf.GPSSamples(p.Elements))
+		return nil
+	}); err != nil {
 		return fmt.Errorf("render: decode %q: %w", args[0], err)
-	}
-
-	if err := gpmf.Walk(data, c.walk); err != nil {
-		return fmt.Errorf("render: walk %q: %w", args[0], err)
 	} else if len(c.data) == 0 {
 		return fmt.Errorf("render: walk %q: no gps data found", args[0])
 	}
@@ -62,23 +60,15 @@ func (c *goproRenderCmd) RunE(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// walk is a gpmf.WalkFunc which looks for GPS data, validates and
-// stores for rendering if it passes.
+// add validates GPS data and stores for rendering if it passes.
 // Validation is based off the GPS Dilution of Precision with only values
 // above MinDoP being used. MinGood is also used to filter out bad data
 // close to the start of the dataset.
-func (c *goproRenderCmd) walk(e *gpmf.Element) error {
-	data, ok := e.Data.(gpmf.GPSData)
-	if !ok {
-		return nil
-	}
-
+func (c *goproRenderCmd) add(data gpmf.GPSData) {
 	for _, v := range data {
-		var dop gpmf.GPSDoP = 100 // Default to bad data.
-		if v, ok := e.MetadataByKey(gpmf.KeyGSPDoP); ok {
-			if f, ok := v.(gpmf.GPSDoP); ok {
-				dop = f
-			}
+		dop := v.DoP
+		if dop == 0 {
+			dop = 100 // Default to bad data.
 		}
 
 		if float64(dop) > c.MinDoP {
@@ -104,8 +94,6 @@ func (c *goproRenderCmd) walk(e *gpmf.Element) error {
 			s2.LatLngFromDegrees(v.Latitude, v.Longitude),
 		)
 	}
-
-	return nil
 }
 
 func addGoproRender() {
@@ -126,6 +114,9 @@ func addGoproRender() {
 	fs.Float64Var(&c.Start.Bearing, "bearing", 0, "override start bearing")
 	fs.Float64Var(&c.Start.Distance, "distance", 0, "override start distance")
 	annotate(fs, "gopro.render")
+	for _, v := range []string{"latitude", "longitude", "bearing", "distance"} {
+		configKey(fs, v, "start."+v)
+	}
 
 	goproCmd.AddCommand(cmd)
 }
@@ -9,10 +9,13 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/stevenh/tracktools/pkg/gopro"
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf"
 )
 
 const (
 	cmdNameAnno = "tracktools_annotation_cmd"
+	cmdKeyAnno  = "tracktools_annotation_key"
 )
 
 // loadConfig loads a config section for cmd into cfg skipping
@@ -26,15 +29,21 @@ func loadConfig(cmd *cobra.Command, cfg any) error {
 	cmd.Flags().Visit(func(f *pflag.Flag) {
 		if v, ok := f.Annotations[cmdNameAnno]; ok && v[0] == name {
 			n := strings.ToLower(strings.ReplaceAll(f.Name, "-", ""))
-			delete(data, n)
+			if k, ok := f.Annotations[cmdKeyAnno]; ok {
+				n = k[0]
+			}
+			deleteKey(data, n)
 			log.Trace().Str("flag", n).Msg("skipped")
 		}
 	})
 
 	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		Metadata:   nil,
-		DecodeHook: mapstructure.TextUnmarshallerHookFunc(),
-		Result:     cfg,
+		Metadata: nil,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.TextUnmarshallerHookFunc(),
+			mapstructure.StringToTimeDurationHookFunc(),
+		),
+		Result: cfg,
 	})
 	if err != nil {
 		return fmt.Errorf("load config: new decoder: %w", err)
@@ -63,6 +72,30 @@ func annotate(fs *pflag.FlagSet, name string) {
 	})
 }
 
+// configKey annotates the flag name in fs with the config key it
+// overrides, for flags which set nested values such as "start.latitude".
+func configKey(fs *pflag.FlagSet, name, key string) {
+	f := fs.Lookup(name)
+	if f.Annotations == nil {
+		f.Annotations = map[string][]string{}
+	}
+	f.Annotations[cmdKeyAnno] = []string{key}
+}
+
+// deleteKey deletes the dot separated key from data.
+func deleteKey(data map[string]any, key string) {
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		v, ok := data[p].(map[string]any)
+		if !ok {
+			return
+		}
+		data = v
+	}
+
+	delete(data, parts[len(parts)-1])
+}
+
 // cmdConfigName returns the config name of cmd.
 func cmdConfigName(cmd *cobra.Command) string {
 	parts := strings.Split(cmd.CommandPath(), " ")
@@ -72,3 +105,45 @@ func cmdConfigName(cmd *cobra.Command) string {
 
 	return strings.Join(parts[1:], ".")
 }
+
+// recordings groups the GoPro video files into recordings, with the
+// chapters of each in order. Files which aren't GoPro chapters, or
+// whose chapters are invalid, are returned as individual recordings.
+func recordings(files []string) ([][]string, error) {
+	sets, other, err := gopro.Sets(files)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([][]string, 0, len(sets)+len(other))
+	for _, s := range sets {
+		if err := s.Chapters.Validate(); err != nil {
+			log.Warn().Err(err).Strs("files", s.Paths()).Msg("invalid chapters, processing separately")
+			for _, f := range s.Paths() {
+				groups = append(groups, []string{f})
+			}
+			continue
+		}
+		groups = append(groups, s.Paths())
+	}
+
+	for _, f := range other {
+		groups = append(groups, []string{f})
+	}
+
+	return groups, nil
+}
+
+// lenientDecoding returns a gpmf.DecoderOption which enables lenient
+// mode, logging a warning for each chunk or chapter skipped.
+func lenientDecoding() gpmf.DecoderOption {
+	return gpmf.Lenient(func(err *gpmf.DecodeError) {
+		log.Warn().
+			Err(err.Err).
+			Int("chapter", err.Chapter+1).
+			Int("chunk", err.Chunk).
+			Int64("offset", err.Offset).
+			Dur("start", err.Start).
+			Msg("skipped corrupt metadata")
+	})
+}
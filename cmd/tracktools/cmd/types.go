@@ -3,6 +3,7 @@ package cmd
 import (
 	"time"
 
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf/geo"
 	"github.com/tidwall/geodesic"
 )
 
@@ -55,7 +56,7 @@ func (d date) Type() string {
 	return "date"
 }
 
-// Start represents a track start point.
+// Start represents a track start point, or a sector line.
 type Start struct {
 	Latitude  float64
 	Longitude float64
@@ -66,6 +67,26 @@ type Start struct {
 	lat2, lon2 float64
 }
 
+// line returns the start line.
+func (s Start) line() geo.Line {
+	return geo.Line{
+		Latitude:  s.Latitude,
+		Longitude: s.Longitude,
+		Bearing:   s.Bearing,
+		Distance:  s.Distance,
+	}
+}
+
+// sectorLines returns the lines of sectors.
+func sectorLines(sectors []Start) []geo.Line {
+	lines := make([]geo.Line, len(sectors))
+	for i, s := range sectors {
+		lines[i] = s.line()
+	}
+
+	return lines
+}
+
 // calculates the start and end latitudes and longitudes of the start line.
 func (s *Start) calculate() {
 	gd.Direct(s.Latitude, s.Longitude, s.Bearing+90, s.Distance, &s.lat1, &s.lon1, nil)
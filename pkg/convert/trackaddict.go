@@ -2,8 +2,10 @@ package convert
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf/geo"
 	"github.com/stevenh/tracktools/pkg/laptimer"
 	"github.com/stevenh/tracktools/pkg/trackaddict"
 	"github.com/tidwall/geodesic"
@@ -24,6 +26,13 @@ type TrackAddict struct {
 	posFixing  laptimer.PositionFixing
 	startDate  time.Time
 	dateAdjust time.Duration
+	sectors    []geo.Line
+	start      *geo.Line
+	finish     *geo.Line
+	proc       *geo.Processor
+
+	headingTolerance float64
+	anyDirection     bool
 }
 
 // Option represents a TrackAddict option.
@@ -111,6 +120,57 @@ func StartDateOpt(date time.Time) Option {
 	}
 }
 
+// SectorsOpt sets the sector lines, in the order they are crossed,
+// used to calculate the Intermediates of each lap in the output of
+// a TrackAddict. Only crossings in the direction of each line's
+// bearing count unless AnyDirectionOpt is used.
+// Default is none.
+func SectorsOpt(lines ...geo.Line) Option {
+	return func(ta *TrackAddict) error {
+		ta.sectors = lines
+
+		return nil
+	}
+}
+
+// PointToPointOpt sets the start and finish lines used to time point
+// to point runs, such as a hillclimb or rally stage, in the output of
+// a TrackAddict instead of using its laps. Runs which reach the finish
+// line are recorded as laptimer.LapRecordingTriggered and a last run
+// which doesn't as laptimer.LapRecordingIncomplete.
+// Default is to use the laps of the session as a circuit.
+func PointToPointOpt(start, finish geo.Line) Option {
+	return func(ta *TrackAddict) error {
+		ta.start = &start
+		ta.finish = &finish
+
+		return nil
+	}
+}
+
+// HeadingToleranceOpt sets the tolerance in degrees between the
+// direction of travel and the bearing of a timing line for a crossing
+// to count in the output of a TrackAddict.
+// Default is geo.DefaultHeadingTolerance.
+func HeadingToleranceOpt(deg float64) Option {
+	return func(ta *TrackAddict) error {
+		ta.headingTolerance = deg
+
+		return nil
+	}
+}
+
+// AnyDirectionOpt counts crossings of the timing lines in either
+// direction in the output of a TrackAddict.
+// Default is only crossings in the direction of each line's bearing.
+func AnyDirectionOpt() Option {
+	return func(ta *TrackAddict) error {
+		ta.anyDirection = true
+
+		return nil
+	}
+}
+
 // NewTrackAddict creates a new TrackAddict with a given set of options.
 func NewTrackAddict(options ...Option) (*TrackAddict, error) {
 	c := &TrackAddict{
@@ -118,6 +178,9 @@ func NewTrackAddict(options ...Option) (*TrackAddict, error) {
 		hdop:       1,
 		diffStatus: laptimer.DifferentialStatusUnknown,
 		posFixing:  laptimer.PositionFixing3D,
+		proc:       geo.NewProcessor(),
+
+		headingTolerance: geo.DefaultHeadingTolerance,
 	}
 	for _, f := range options {
 		if err := f(c); err != nil {
@@ -142,6 +205,11 @@ func (ta *TrackAddict) LapTimer(s *trackaddict.Session) (*laptimer.DB, error) {
 		}
 	}
 
+	if ta.finish != nil {
+		ta.lapTimerRuns(db, s, vehicle)
+		return db, nil
+	}
+
 	// First lap is the outlap and last is the in lap.
 	// TODO(steve): should we filter it better?
 	if len(s.Laps) < 3 {
@@ -150,7 +218,13 @@ func (ta *TrackAddict) LapTimer(s *trackaddict.Session) (*laptimer.DB, error) {
 
 	fixID := 1
 	for _, l := range s.Laps[1 : len(s.Laps)-1] {
-		lap := ta.lapTimerLap(l, vehicle, fixID)
+		var firstNow time.Duration
+		if len(l.Records) > 0 {
+			firstNow = l.Records[0].Now
+		}
+		lap := ta.lapTimerLap(l, vehicle, fixID, func(r trackaddict.Record) time.Duration {
+			return r.Now - firstNow
+		})
 		db.Laps = append(db.Laps, lap)
 		fixID += len(lap.Recording.Fixes)
 	}
@@ -158,8 +232,71 @@ func (ta *TrackAddict) LapTimer(s *trackaddict.Session) (*laptimer.DB, error) {
 	return db, nil
 }
 
-// lapTimerLap returns laptimer.Lap representation of l.
-func (ta *TrackAddict) lapTimerLap(l *trackaddict.Lap, vehicle string, id int) laptimer.Lap {
+// lapTimerRuns adds the point to point runs of s to db.
+func (ta *TrackAddict) lapTimerRuns(db *laptimer.DB, s *trackaddict.Session, vehicle string) {
+	var records []trackaddict.Record
+	for _, l := range s.Laps {
+		records = append(records, l.Records...)
+	}
+
+	if len(records) == 0 {
+		return
+	}
+
+	first := records[0].Time
+	opts := []geo.TimerOption{
+		geo.Finish(*ta.finish),
+		geo.HeadingTolerance(ta.headingTolerance),
+	}
+	if ta.anyDirection {
+		opts = append(opts, geo.AnyDirection())
+	}
+	timer := geo.NewTimer(ta.proc, *ta.start, opts...)
+	for _, r := range records {
+		if r.GPS.Update {
+			timer.Add(geo.Fix{
+				Latitude:  r.GPS.Latitude,
+				Longitude: r.GPS.Longitude,
+				Offset:    r.Time.Sub(first),
+			})
+		}
+	}
+
+	fixID := 1
+	for _, run := range timer.Laps() {
+		// Records after the start up to the finish.
+		i := sort.Search(len(records), func(i int) bool {
+			return records[i].Time.Sub(first) > run.Start
+		})
+		j := sort.Search(len(records), func(i int) bool {
+			return records[i].Time.Sub(first) > run.End
+		})
+		l := &trackaddict.Lap{
+			Number:   run.Number,
+			Duration: run.Time,
+			Records:  records[i:j],
+		}
+
+		// Records times are relative to their TrackAddict lap, which
+		// runs don't align with, and the run starts between records.
+		lap := ta.lapTimerLap(l, vehicle, fixID, func(r trackaddict.Record) time.Duration {
+			return r.Time.Sub(first) - run.Start
+		})
+		if run.Type == geo.LapIn {
+			lap.LapRecordingType = laptimer.LapRecordingIncomplete
+		}
+		db.Laps = append(db.Laps, lap)
+		fixID += len(lap.Recording.Fixes)
+	}
+}
+
+// lapTimerLap returns laptimer.Lap representation of l, where offset
+// returns the offset of a record from the start of the lap.
+func (ta *TrackAddict) lapTimerLap(l *trackaddict.Lap,
+	vehicle string,
+	id int,
+	offset func(r trackaddict.Record) time.Duration,
+) laptimer.Lap {
 	lap := laptimer.Lap{
 		ID:               l.Number,
 		LapTime:          laptimer.Duration(l.Duration),
@@ -177,7 +314,6 @@ func (ta *TrackAddict) lapTimerLap(l *trackaddict.Lap, vehicle string, id int) l
 	var dist, d float64
 	r := l.Records[0]
 	lastGPS := r.GPS
-	firstNow := r.Now
 
 	if !ta.startDate.IsZero() && ta.dateAdjust == 0 {
 		y, m, d := r.Time.UTC().Date()
@@ -187,11 +323,17 @@ func (ta *TrackAddict) lapTimerLap(l *trackaddict.Lap, vehicle string, id int) l
 
 	lap.Date = laptimer.LapDate(r.Time.Add(ta.dateAdjust))
 
+	detectors := make([]*geo.LineDetector, len(ta.sectors))
+	for i, s := range ta.sectors {
+		detectors[i] = ta.lineDetector(s)
+	}
+	splitter := geo.NewSplitter(detectors...)
 	for j, r := range l.Records {
 		if j != 0 && !r.GPS.Update {
 			continue
 		}
 
+		prevDist := dist
 		if j > 0 && r.GPS.Update {
 			geodesic.WGS84.Inverse(
 				lastGPS.Latitude,
@@ -204,8 +346,26 @@ func (ta *TrackAddict) lapTimerLap(l *trackaddict.Lap, vehicle string, id int) l
 			lastGPS = r.GPS
 		}
 
+		if c, _, ok := splitter.Add(geo.Fix{
+			Latitude:  r.GPS.Latitude,
+			Longitude: r.GPS.Longitude,
+			Offset:    offset(r),
+		}); ok {
+			geodesic.WGS84.Inverse(
+				c.From.Latitude,
+				c.From.Longitude,
+				c.Latitude,
+				c.Longitude,
+				&d, nil, nil,
+			)
+			lap.Intermediates = append(lap.Intermediates, laptimer.Intermediate{
+				Time:     laptimer.Duration(c.Offset),
+				Distance: float64(round1dp(prevDist + d)),
+			})
+		}
+
 		lap.Recording.Fixes = append(lap.Recording.Fixes,
-			ta.lapTimerFix(id, dist, r, firstNow),
+			ta.lapTimerFix(id, dist, r, offset(r)),
 		)
 		id++
 	}
@@ -215,8 +375,18 @@ func (ta *TrackAddict) lapTimerLap(l *trackaddict.Lap, vehicle string, id int) l
 	return lap
 }
 
-// lapTimerFix returns a laptimer.Fix representation of the data in r.
-func (ta *TrackAddict) lapTimerFix(id int, dist float64, r trackaddict.Record, firstNow time.Duration) laptimer.Fix {
+// lineDetector returns the LineDetector for the timing line l.
+func (ta *TrackAddict) lineDetector(l geo.Line) *geo.LineDetector {
+	if ta.anyDirection {
+		return l.Detector(ta.proc)
+	}
+
+	return l.Detector(ta.proc, geo.Heading(l.Bearing, ta.headingTolerance))
+}
+
+// lapTimerFix returns a laptimer.Fix representation of the data in r
+// which is offset from the start of the lap.
+func (ta *TrackAddict) lapTimerFix(id int, dist float64, r trackaddict.Record, offset time.Duration) laptimer.Fix {
 	f := laptimer.Fix{
 		ID:   id,
 		Date: laptimer.FixDate(r.Time.Add(ta.dateAdjust)),
@@ -239,7 +409,7 @@ func (ta *TrackAddict) lapTimerFix(id int, dist float64, r trackaddict.Record, f
 		Accuracy:   round1dp(r.GPS.Accuracy),
 		RelativeToStart: laptimer.RelativeToStart{
 			Distance: dist,
-			Offset:   laptimer.Duration(r.Now - firstNow),
+			Offset:   laptimer.Duration(offset),
 		},
 	}
 
@@ -4,7 +4,9 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf/geo"
 	"github.com/stevenh/tracktools/pkg/laptimer"
 	"github.com/stevenh/tracktools/pkg/trackaddict"
 	"github.com/stretchr/testify/require"
@@ -39,4 +41,133 @@ func TestTrackAddict(t *testing.T) {
 
 	err = enc.Encode(db)
 	require.NoError(t, err)
+	require.Empty(t, db.Laps[0].Intermediates)
+
+	t.Run("sectors", func(t *testing.T) {
+		conv, err := NewTrackAddict(SectorsOpt(
+			geo.Line{Latitude: 50.8572556, Longitude: -0.7641642, Bearing: 323.7, Distance: 15},
+			geo.Line{Latitude: 50.8634530, Longitude: -0.7612123, Bearing: 98.9, Distance: 15},
+		))
+		require.NoError(t, err)
+
+		db, err := conv.LapTimer(sess)
+		require.NoError(t, err)
+		require.Len(t, db.Laps, 1)
+
+		lap := db.Laps[0]
+		require.Len(t, lap.Intermediates, 2)
+		require.InDelta(t, 58*time.Second, time.Duration(lap.Intermediates[0].Time), float64(time.Second))
+		require.InDelta(t, 117*time.Second, time.Duration(lap.Intermediates[1].Time), float64(time.Second))
+		require.Less(t, lap.Intermediates[0].Distance, lap.Intermediates[1].Distance)
+		require.Less(t, lap.Intermediates[1].Distance, float64(lap.OverallDistance))
+	})
+
+	t.Run("any-direction", func(t *testing.T) {
+		// Sector lines only crossed backwards.
+		sectors := []geo.Line{
+			{Latitude: 50.8572556, Longitude: -0.7641642, Bearing: 143.7, Distance: 15},
+			{Latitude: 50.8634530, Longitude: -0.7612123, Bearing: 278.9, Distance: 15},
+		}
+		conv, err := NewTrackAddict(SectorsOpt(sectors...))
+		require.NoError(t, err)
+
+		db, err := conv.LapTimer(sess)
+		require.NoError(t, err)
+		require.Len(t, db.Laps, 1)
+		require.Empty(t, db.Laps[0].Intermediates)
+
+		conv, err = NewTrackAddict(SectorsOpt(sectors...), AnyDirectionOpt())
+		require.NoError(t, err)
+
+		db, err = conv.LapTimer(sess)
+		require.NoError(t, err)
+		require.Len(t, db.Laps, 1)
+		require.Len(t, db.Laps[0].Intermediates, 2)
+
+		conv, err = NewTrackAddict(SectorsOpt(sectors...), HeadingToleranceOpt(180))
+		require.NoError(t, err)
+
+		db, err = conv.LapTimer(sess)
+		require.NoError(t, err)
+		require.Len(t, db.Laps, 1)
+		require.Len(t, db.Laps[0].Intermediates, 2)
+	})
+
+	t.Run("point-to-point", func(t *testing.T) {
+		start := geo.Line{Latitude: 50.8572556, Longitude: -0.7641642, Bearing: 323.7, Distance: 15}
+		finish := geo.Line{Latitude: 50.8634530, Longitude: -0.7612123, Bearing: 98.9, Distance: 15}
+		conv, err := NewTrackAddict(PointToPointOpt(start, finish))
+		require.NoError(t, err)
+
+		db, err := conv.LapTimer(sess)
+		require.NoError(t, err)
+		require.Len(t, db.Laps, 2)
+		for i, l := range db.Laps {
+			require.Equal(t, i+1, l.ID)
+			require.Equal(t, laptimer.LapRecordingTriggered, l.LapRecordingType)
+			require.Greater(t, time.Duration(l.LapTime), 45*time.Second)
+			require.Less(t, time.Duration(l.LapTime), time.Minute)
+			require.NotEmpty(t, l.Recording.Fixes)
+
+			// Fix offsets are from the start line crossing.
+			fixes := l.Recording.Fixes
+			require.GreaterOrEqual(t, fixes[0].RelativeToStart.Offset, laptimer.Duration(0))
+			require.Less(t, time.Duration(fixes[0].RelativeToStart.Offset), time.Second)
+			for j := 1; j < len(fixes); j++ {
+				require.Greater(t, fixes[j].RelativeToStart.Offset, fixes[j-1].RelativeToStart.Offset)
+			}
+			require.LessOrEqual(t, fixes[len(fixes)-1].RelativeToStart.Offset, l.LapTime)
+		}
+
+		// Intermediates are measured from the start line crossing, so
+		// match the splits of a timer over the same fixes.
+		var records []trackaddict.Record
+		for _, l := range sess.Laps {
+			records = append(records, l.Records...)
+		}
+		first := records[0].Time
+		runs := func(opts ...geo.TimerOption) []geo.Lap {
+			timer := geo.NewTimer(geo.NewProcessor(), start, append(opts, geo.Finish(finish))...)
+			for _, r := range records {
+				if r.GPS.Update {
+					timer.Add(geo.Fix{Latitude: r.GPS.Latitude, Longitude: r.GPS.Longitude, Offset: r.Time.Sub(first)})
+				}
+			}
+			laps := timer.Laps()
+			require.Len(t, laps, 2)
+			return laps
+		}
+
+		// Sector line half way through the first run.
+		run := runs()[0]
+		var mid geo.Line
+		for _, r := range records {
+			if r.GPS.Update && r.Time.Sub(first) > run.Start+run.Time/2 {
+				mid = geo.Line{Latitude: r.GPS.Latitude, Longitude: r.GPS.Longitude, Bearing: r.GPS.Heading, Distance: 15}
+				break
+			}
+		}
+		expected := runs(geo.Sectors(mid))
+
+		conv, err = NewTrackAddict(PointToPointOpt(start, finish), SectorsOpt(mid))
+		require.NoError(t, err)
+		db, err = conv.LapTimer(sess)
+		require.NoError(t, err)
+		require.Len(t, db.Laps, 2)
+		for i, l := range db.Laps {
+			require.Len(t, expected[i].Splits, 1)
+			require.Len(t, l.Intermediates, 1)
+			require.InDelta(t, expected[i].Splits[0].Time, time.Duration(l.Intermediates[0].Time), float64(time.Millisecond))
+		}
+
+		// Finish line which is only crossed backwards.
+		finish.Bearing += 180
+		conv, err = NewTrackAddict(PointToPointOpt(start, finish))
+		require.NoError(t, err)
+
+		db, err = conv.LapTimer(sess)
+		require.NoError(t, err)
+		require.Len(t, db.Laps, 1)
+		require.Equal(t, laptimer.LapRecordingIncomplete, db.Laps[0].LapRecordingType)
+	})
 }
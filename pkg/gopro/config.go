@@ -50,6 +50,10 @@ type Config struct {
 	OutputDir      string
 	Overwrite      bool
 
+	// HiLightChapters adds a chapter to the joined output for each
+	// HiLight tag in the source files.
+	HiLightChapters bool
+
 	skip       map[string]struct{}
 	logLevel   zerolog.Level
 	inputIndex int
@@ -1,7 +1,9 @@
 package gopro
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sort"
 )
 
@@ -65,6 +67,9 @@ func (s FileSlice) Validate() error {
 type FileSet struct {
 	Number   string
 	Chapters FileSlice
+
+	// Dir is the directory containing the files.
+	Dir string
 }
 
 // Chapter adds file as a chapter ensuring correct order.
@@ -72,3 +77,62 @@ func (s *FileSet) Chapter(f *File) {
 	s.Chapters = append(s.Chapters, *f)
 	sort.Sort(s.Chapters)
 }
+
+// Paths returns the paths of the chapters in order.
+func (s *FileSet) Paths() []string {
+	paths := make([]string, len(s.Chapters))
+	for i, f := range s.Chapters {
+		paths[i] = filepath.Join(s.Dir, f.Name)
+	}
+
+	return paths
+}
+
+// Sets groups files into FileSets using matchers, defaulting to Hero5
+// and Hero10 if none are specified, returning the sets in the order
+// they were first seen and any files which didn't match.
+func Sets(files []string, matchers ...*Matcher) ([]*FileSet, []string, error) {
+	if len(matchers) == 0 {
+		matchers = []*Matcher{Hero5, Hero10}
+	}
+
+	var sets []*FileSet
+	var other []string
+	index := make(map[string]*FileSet)
+	for _, file := range files {
+		f, err := match(file, matchers)
+		switch {
+		case errors.Is(err, ErrNoMatch):
+			other = append(other, file)
+			continue
+		case err != nil:
+			return nil, nil, err
+		}
+
+		dir := filepath.Dir(file)
+		key := filepath.Join(dir, f.Index)
+		s, ok := index[key]
+		if !ok {
+			s = &FileSet{Number: f.Index, Dir: dir}
+			index[key] = s
+			sets = append(sets, s)
+		}
+		s.Chapter(f)
+	}
+
+	return sets, other, nil
+}
+
+// match returns the File for the first of matchers which matches file.
+func match(file string, matchers []*Matcher) (*File, error) {
+	for _, m := range matchers {
+		f, err := m.Match(file)
+		if errors.Is(err, ErrNoMatch) {
+			continue
+		}
+
+		return f, err
+	}
+
+	return nil, ErrNoMatch
+}
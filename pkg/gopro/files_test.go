@@ -64,3 +64,23 @@ func TestFileSlice(t *testing.T) {
 		})
 	}
 }
+
+func TestSets(t *testing.T) {
+	sets, other, err := Sets([]string{
+		"a/GX020001.MP4",
+		"a/GX010001.MP4",
+		"b/GX010001.MP4",
+		"a/GOPR0002.MP4",
+		"a/GP010002.MP4",
+		"a/notes.txt",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a/notes.txt"}, other)
+	require.Len(t, sets, 3)
+
+	require.Equal(t, "0001", sets[0].Number)
+	require.Equal(t, []string{"a/GX010001.MP4", "a/GX020001.MP4"}, sets[0].Paths())
+	require.Equal(t, []string{"b/GX010001.MP4"}, sets[1].Paths())
+	require.Equal(t, []string{"a/GOPR0002.MP4", "a/GP010002.MP4"}, sets[2].Paths())
+	require.NoError(t, sets[2].Chapters.Validate())
+}
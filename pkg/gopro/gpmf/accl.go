@@ -7,7 +7,17 @@ import (
 // AccelData represents acceleration data.
 type AccelData []Accel
 
-// Accel represents acceleration for each axes.
+// Accel represents acceleration for each axes in m/s².
+//
+// Axes are in the right-handed camera frame, unless MountRotation is
+// set, where with the camera upright +X is to the right looking through
+// the lens from behind the camera, +Y is forward out of the lens and +Z
+// is up out of the top of the camera. This matches the up/down,
+// right/left, forward/back channel order GoPro cameras name accelerometer
+// streams with, mapped to Z, X and Y by the default ORIN of ZXY, while
+// cameras which record ORIN, ORIO or MTRX are calibrated to the same
+// axes. The sensor measures the reaction to gravity, so an upright
+// camera at rest reads about +9.8 m/s² on Z.
 type Accel struct {
 	X      float64
 	Y      float64
@@ -26,11 +36,11 @@ func (d AccelData) offsets(start, end time.Duration) {
 
 func parseAccel(e *Element) error {
 	e.initMetadata()
-	return floatType[AccelData](e, 3, func(vals []float64) Accel {
+	return imuType[AccelData](e, func(v [3]float64) Accel {
 		return Accel{
-			Z: vals[0],
-			X: vals[1],
-			Y: vals[2],
+			X: v[0],
+			Y: v[1],
+			Z: v[2],
 		}
 	})
 }
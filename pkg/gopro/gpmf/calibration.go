@@ -0,0 +1,170 @@
+package gpmf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// defaultOrientationIn is the channel orientation of IMU data in
+	// streams without ORIN, as used by HERO5 to HERO7 cameras.
+	defaultOrientationIn = "ZXY"
+
+	// defaultOrientationOut is the output orientation of IMU data in
+	// streams without ORIO.
+	defaultOrientationOut = "XYZ"
+)
+
+// transform is a 3x3 matrix which transforms a vector.
+type transform [3][3]float64
+
+// identity is the identity transform.
+var identity = transform{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+// mul returns t x o.
+func (t transform) mul(o transform) transform {
+	var r transform
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				r[i][j] += t[i][k] * o[k][j]
+			}
+		}
+	}
+
+	return r
+}
+
+// apply returns v transformed by t.
+func (t transform) apply(v [3]float64) [3]float64 {
+	var r [3]float64
+	for i := range 3 {
+		r[i] = t[i][0]*v[0] + t[i][1]*v[1] + t[i][2]*v[2]
+	}
+
+	return r
+}
+
+// rotation returns the transform for a rotation by roll about X, pitch
+// about Y and yaw about Z, in degrees, applied in that order.
+func rotation(roll, pitch, yaw float64) transform {
+	r, p, y := roll*math.Pi/180, pitch*math.Pi/180, yaw*math.Pi/180
+	rx := transform{{1, 0, 0}, {0, math.Cos(r), -math.Sin(r)}, {0, math.Sin(r), math.Cos(r)}}
+	ry := transform{{math.Cos(p), 0, math.Sin(p)}, {0, 1, 0}, {-math.Sin(p), 0, math.Cos(p)}}
+	rz := transform{{math.Cos(y), -math.Sin(y), 0}, {math.Sin(y), math.Cos(y), 0}, {0, 0, 1}}
+
+	return rz.mul(ry).mul(rx)
+}
+
+// axis returns the index and sign of the axis c which must be one of
+// X, Y or Z with lowercase representing negative.
+func axis(c byte) (int, float64, error) {
+	sign := 1.0
+	if c >= 'a' && c <= 'z' {
+		sign = -1
+		c -= 'a' - 'A'
+	}
+
+	if c < 'X' || c > 'Z' {
+		return 0, 0, fmt.Errorf("invalid axis %q", c)
+	}
+
+	return int(c - 'X'), sign, nil
+}
+
+// orientation returns the orientation string metadata for key, def
+// if not present.
+func (e *Element) orientation(key, def string) (string, error) {
+	v, ok := e.lookup(friendlyName(key))
+	if !ok {
+		return def, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s: unexpected type %T (expected string)", key, v)
+	}
+
+	s = strings.TrimRight(s, "\x00")
+	if len(s) != 3 {
+		return "", fmt.Errorf("%s: unsupported orientation %q (expected 3 axes)", key, s)
+	}
+
+	return s, nil
+}
+
+// calibration returns the transform which converts three channel IMU
+// data of e into X, Y and Z in the camera frame.
+//
+// The channels are mapped to the axes of ORIO, either by the MTRX
+// calibration matrix if present or by the channel orientation ORIN,
+// which defaults to ZXY. The ORIO axes are then mapped to X, Y and Z.
+// The resulting frame is fixed to the camera body with +X right, +Y out
+// of the lens and +Z up, see Accel and MountRotation.
+func (e *Element) calibration() (transform, error) {
+	in, err := e.orientation(KeyOrientationIn, defaultOrientationIn)
+	if err != nil {
+		return transform{}, err
+	}
+
+	out, err := e.orientation(KeyOrientationOut, defaultOrientationOut)
+	if err != nil {
+		return transform{}, err
+	}
+
+	// Map output channels to X, Y and Z.
+	var axes transform
+	for j := range 3 {
+		k, sign, err := axis(out[j])
+		if err != nil {
+			return transform{}, fmt.Errorf("%s: %w", KeyOrientationOut, err)
+		}
+		axes[k][j] = sign
+	}
+
+	var channels transform
+	if v, ok := e.lookup(friendlyName(KeyMatrix)); ok {
+		m, err := floatSlice(v)
+		if err != nil {
+			return transform{}, fmt.Errorf("%s: %w", KeyMatrix, err)
+		} else if len(m) != 9 {
+			return transform{}, fmt.Errorf("%s: unsupported size %d (expected 9)", KeyMatrix, len(m))
+		}
+
+		for i := range 3 {
+			copy(channels[i][:], m[i*3:(i+1)*3])
+		}
+
+		return axes.mul(channels), nil
+	}
+
+	// Map input channels to output channels.
+	for i := range 3 {
+		_, sign, err := axis(in[i])
+		if err != nil {
+			return transform{}, fmt.Errorf("%s: %w", KeyOrientationIn, err)
+		}
+
+		j := strings.IndexByte(strings.ToUpper(out), in[i]&^0x20)
+		if j == -1 {
+			return transform{}, fmt.Errorf("%s: axis %q not in %s %q", KeyOrientationIn, in[i], KeyOrientationOut, out)
+		}
+		channels[j][i] = sign
+	}
+
+	return axes.mul(channels), nil
+}
+
+// imuType returns a slice of T created from the calibrated three
+// channel data of e using fn.
+func imuType[T ~[]E, E any](e *Element, fn func(v [3]float64) E) error {
+	t, err := e.calibration()
+	if err != nil {
+		return fmt.Errorf("%s: calibration: %w", e.FriendlyName(), err)
+	}
+
+	return floatType[T](e, 3, func(vals []float64) E {
+		return fn(t.apply([3]float64{vals[0], vals[1], vals[2]}))
+	})
+}
@@ -0,0 +1,104 @@
+package gpmf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalibration(t *testing.T) {
+	str := func(key, v string) []byte {
+		return klv(key, String, 1, uint16(len(v)), []byte(v)) //nolint: gosec
+	}
+	accl := klv(KeyAccel, Int16, 6, 1, be(t, int16(1), int16(2), int16(3)))
+	mtrx := klv(KeyMatrix, Float32, 4, 9, be(t,
+		float32(0), float32(1), float32(0),
+		float32(1), float32(0), float32(0),
+		float32(0), float32(0), float32(-1),
+	))
+
+	tests := []struct {
+		name     string
+		elems    [][]byte
+		options  []ReaderOption
+		expected Accel
+		err      bool
+	}{
+		{
+			name:     "default",
+			elems:    [][]byte{accl},
+			expected: Accel{X: 2, Y: 3, Z: 1},
+		},
+		{
+			name:     "orin",
+			elems:    [][]byte{str(KeyOrientationIn, "YxZ"), str(KeyOrientationOut, "XYZ"), accl},
+			expected: Accel{X: -2, Y: 1, Z: 3},
+		},
+		{
+			name:     "orio",
+			elems:    [][]byte{str(KeyOrientationIn, "XYZ"), str(KeyOrientationOut, "ZXY"), accl},
+			expected: Accel{X: 1, Y: 2, Z: 3},
+		},
+		{
+			name:     "matrix",
+			elems:    [][]byte{str(KeyOrientationIn, "ZXY"), str(KeyOrientationOut, "XYZ"), mtrx, accl},
+			expected: Accel{X: 2, Y: 1, Z: -3},
+		},
+		{
+			name:     "mount",
+			elems:    [][]byte{str(KeyOrientationIn, "XYZ"), accl},
+			options:  []ReaderOption{MountRotation(180, 0, 0)},
+			expected: Accel{X: 1, Y: -2, Z: -3},
+		},
+		{
+			name:     "mount-yaw",
+			elems:    [][]byte{str(KeyOrientationIn, "XYZ"), accl},
+			options:  []ReaderOption{MountRotation(0, 0, 90)},
+			expected: Accel{X: -2, Y: 1, Z: 3},
+		},
+		{
+			// Channels are up, right and forward for an upright camera.
+			name:     "mount-forward",
+			elems:    [][]byte{accl},
+			options:  []ReaderOption{MountRotation(0, 0, -90)},
+			expected: Accel{X: 3, Y: -2, Z: 1},
+		},
+		{
+			name:     "mount-upside-down",
+			elems:    [][]byte{accl},
+			options:  []ReaderOption{MountRotation(180, 0, 90)},
+			expected: Accel{X: 3, Y: 2, Z: -1},
+		},
+		{
+			name:  "invalid-orin",
+			elems: [][]byte{str(KeyOrientationIn, "ABC"), accl},
+			err:   true,
+		},
+		{
+			name:  "missing-axis",
+			elems: [][]byte{str(KeyOrientationIn, "XXZ"), str(KeyOrientationOut, "XYZW"), accl},
+			err:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := bytes.Join(tc.elems, nil)
+			strm := klv(KeyStream, Nested, 1, uint16(len(b)), b) //nolint: gosec
+			res, err := NewReader(tc.options...).Read(bytes.NewReader(strm))
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			d, ok := res[0].Nested[len(tc.elems)-1].Data.(AccelData)
+			require.True(t, ok)
+			require.Len(t, d, 1)
+			require.InDelta(t, tc.expected.X, d[0].X, 1e-9)
+			require.InDelta(t, tc.expected.Y, d[0].Y, 1e-9)
+			require.InDelta(t, tc.expected.Z, d[0].Z, 1e-9)
+		})
+	}
+}
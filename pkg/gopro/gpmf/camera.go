@@ -0,0 +1,229 @@
+package gpmf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+const (
+	// udtaGPMF is the udta box type which contains global GPMF data.
+	udtaGPMF = "GPMF"
+
+	// udtaFirmware is the udta box type which contains the firmware
+	// version on cameras which predate udta GPMF.
+	udtaFirmware = "FIRM"
+
+	// videoHandlerType is the handler type of video tracks.
+	videoHandlerType = "vide"
+
+	// boxHeaderSize is the size of a normal mp4 box header.
+	boxHeaderSize = 8
+)
+
+// CameraInfo represents the camera hardware and settings used to
+// record a video, as recorded in the global GPMF data of the mp4
+// moov/udta box, along with details of the video track.
+type CameraInfo struct {
+	// Model is the camera model e.g. "HERO10 Black".
+	Model string `json:",omitempty"`
+
+	// Firmware is the camera firmware version.
+	Firmware string `json:",omitempty"`
+
+	// SerialNumber is the camera serial number.
+	SerialNumber string `json:",omitempty"`
+
+	// MediaUID is the unique ID of the video.
+	MediaUID string `json:",omitempty"`
+
+	// FieldOfView is the field of view setting e.g. "W" for wide.
+	FieldOfView string `json:",omitempty"`
+
+	// DiagonalFieldOfView is the diagonal field of view in degrees.
+	DiagonalFieldOfView float64 `json:",omitempty"`
+
+	// Stabilization is true if electronic image stabilisation was enabled.
+	Stabilization bool
+
+	// ProTune is true if ProTune was enabled.
+	ProTune bool
+
+	// Width is the width of the video in pixels.
+	Width int `json:",omitempty"`
+
+	// Height is the height of the video in pixels.
+	Height int `json:",omitempty"`
+
+	// FrameRate is the frame rate of the video in frames per second.
+	FrameRate float64 `json:",omitempty"`
+
+	// Settings are all the values from the global GPMF data by key.
+	Settings map[string]any `json:",omitempty"`
+}
+
+// cameraInfo returns the CameraInfo for f, nil if it has none.
+func (d *Decoder) cameraInfo(f *mp4.File) (*CameraInfo, error) {
+	if f.Moov == nil {
+		return nil, nil
+	}
+
+	var ci CameraInfo
+	var found bool
+	for _, b := range f.Moov.Children {
+		switch b := b.(type) {
+		case *mp4.UdtaBox:
+			ok, err := d.udta(b, &ci)
+			if err != nil {
+				return nil, err
+			}
+			found = found || ok
+		case *mp4.TrakBox:
+			if b.Mdia == nil || b.Mdia.Hdlr == nil || b.Mdia.Hdlr.HandlerType != videoHandlerType || ci.Width != 0 {
+				continue
+			}
+
+			found = true
+			if b.Tkhd != nil {
+				ci.Width = int(b.Tkhd.Width >> 16)
+				ci.Height = int(b.Tkhd.Height >> 16)
+			}
+
+			stts := b.Mdia.Minf.Stbl.Stts
+			if b.Mdia.Mdhd != nil && stts != nil && len(stts.SampleTimeDelta) > 0 && stts.SampleTimeDelta[0] != 0 {
+				ci.FrameRate = float64(b.Mdia.Mdhd.Timescale) / float64(stts.SampleTimeDelta[0])
+			}
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return &ci, nil
+}
+
+// udta updates ci from the udta box b, returning true if any
+// camera information was found.
+func (d *Decoder) udta(b *mp4.UdtaBox, ci *CameraInfo) (bool, error) {
+	var found bool
+	for _, c := range b.Children {
+		switch c.Type() {
+		case udtaGPMF:
+			data, err := boxPayload(c)
+			if err != nil {
+				return false, fmt.Errorf("udta %s: %w", udtaGPMF, err)
+			}
+
+			elems, err := d.readerOrDefault().Read(bytes.NewReader(data))
+			if err != nil {
+				return false, fmt.Errorf("udta %s: %w", udtaGPMF, err)
+			}
+
+			ci.settings(elems)
+			found = true
+		case udtaFirmware:
+			data, err := boxPayload(c)
+			if err != nil {
+				return false, fmt.Errorf("udta %s: %w", udtaFirmware, err)
+			}
+
+			if ci.Firmware == "" {
+				ci.Firmware = strings.TrimRight(string(data), "\x00 ")
+			}
+			found = true
+		}
+	}
+
+	return found, nil
+}
+
+// settings sets the fields of ci from the global GPMF elements.
+func (ci *CameraInfo) settings(elems []*Element) {
+	if ci.Settings == nil {
+		ci.Settings = make(map[string]any)
+	}
+
+	Walk(elems, func(e *Element) error { //nolint: errcheck // Never returns an error.
+		if e.Header.Nested() {
+			return nil
+		}
+
+		key := e.Header.FourCC()
+		ci.Settings[key] = e.Data
+		switch key {
+		case KeyModel:
+			ci.Model = settingString(e.Data)
+		case KeyFirmware:
+			ci.Firmware = settingString(e.Data)
+		case KeyCameraSerial:
+			ci.SerialNumber = settingString(e.Data)
+		case KeyMediaUID:
+			ci.MediaUID = settingHex(e.Data)
+		case KeyFieldOfView:
+			ci.FieldOfView = settingString(e.Data)
+		case KeyDiagonalFieldOfView:
+			ci.DiagonalFieldOfView = toFloat(e.Data)
+		case KeyStabilization:
+			ci.Stabilization = settingBool(e.Data)
+		case KeyProTune:
+			ci.ProTune = settingBool(e.Data)
+		}
+
+		return nil
+	})
+}
+
+// settingString returns v as a string.
+func settingString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, "")
+	case []byte:
+		return strings.TrimRight(string(v), "\x00")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// settingBool returns true if v represents an enabled setting.
+func settingBool(v any) bool {
+	switch s := strings.ToUpper(settingString(v)); s {
+	case "Y", "YES", "ON", "1", "TRUE":
+		return true
+	default:
+		return false
+	}
+}
+
+// settingHex returns numeric v as hex.
+func settingHex(v any) string {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, byteOrder, v); err != nil {
+		return settingString(v)
+	}
+
+	return fmt.Sprintf("%x", buf.Bytes())
+}
+
+// boxPayload returns the payload of b excluding its header. The udta
+// boxes which contain camera information and HiLights are decoded by
+// mp4ff as unknown boxes, whose payload is only available by encoding
+// them, which always uses a normal size header.
+func boxPayload(b mp4.Box) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := b.Encode(&buf); err != nil {
+		return nil, fmt.Errorf("encode %s: %w", b.Type(), err)
+	}
+
+	if buf.Len() < boxHeaderSize {
+		return nil, fmt.Errorf("encode %s: short box %d", b.Type(), buf.Len())
+	}
+
+	return buf.Bytes()[boxHeaderSize:], nil
+}
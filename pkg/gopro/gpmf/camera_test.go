@@ -0,0 +1,115 @@
+package gpmf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+// testBox returns an unknown box of type name containing data.
+func testBox(t *testing.T, name string, data []byte) mp4.Box {
+	t.Helper()
+	hdr := mp4.BoxHeader{Name: name, Size: uint64(8 + len(data)), Hdrlen: 8}
+	b, err := mp4.DecodeUnknown(hdr, 0, bytes.NewReader(data))
+	require.NoError(t, err)
+	return b
+}
+
+func TestCameraInfo(t *testing.T) {
+	str := func(key, v string) []byte {
+		return klv(key, String, 1, uint16(len(v)), []byte(v)) //nolint: gosec
+	}
+
+	gpmf := bytes.Join([][]byte{
+		str(KeyFirmware, "H21.01.01.62.00"),
+		str(KeyCameraSerial, "C3461325012345"),
+		str(KeyModel, "HERO10 Black"),
+		klv(KeyMediaUID, Uint32, 4, 2, be(t, uint32(0xdeadbeef), uint32(1))),
+		str(KeyFieldOfView, "L"),
+		klv(KeyDiagonalFieldOfView, Float32, 4, 1, be(t, float32(121.5))),
+		str(KeyStabilization, "Y"),
+		str(KeyProTune, "N"),
+		str("BROD", "off"),
+	}, nil)
+
+	udta := &mp4.UdtaBox{}
+	udta.AddChild(testBox(t, udtaGPMF, gpmf))
+
+	video := mp4.CreateEmptyTrak(2, 60000, videoHandlerType, "und")
+	video.Tkhd.Width = mp4.Fixed32(1920 << 16)
+	video.Tkhd.Height = mp4.Fixed32(1080 << 16)
+	video.Mdia.Minf.Stbl.Stts.SampleCount = []uint32{10}
+	video.Mdia.Minf.Stbl.Stts.SampleTimeDelta = []uint32{1001}
+
+	payloads := testPayloads(t, "hero6-multi-chunk")[:2]
+	file := testMP4(t, payloads, 1001, udta, video)
+
+	dec := NewDecoder()
+	ci, err := dec.DecodeCameraInfo(bytes.NewReader(file))
+	require.NoError(t, err)
+	require.NotNil(t, ci)
+	require.Equal(t, "HERO10 Black", ci.Model)
+	require.Equal(t, "H21.01.01.62.00", ci.Firmware)
+	require.Equal(t, "C3461325012345", ci.SerialNumber)
+	require.Equal(t, "deadbeef00000001", ci.MediaUID)
+	require.Equal(t, "L", ci.FieldOfView)
+	require.InDelta(t, 121.5, ci.DiagonalFieldOfView, 1e-6)
+	require.True(t, ci.Stabilization)
+	require.False(t, ci.ProTune)
+	require.Equal(t, 1920, ci.Width)
+	require.Equal(t, 1080, ci.Height)
+	require.InDelta(t, 59.94, ci.FrameRate, 0.01)
+	require.Equal(t, "off", ci.Settings["BROD"])
+
+	var got []*Payload
+	require.NoError(t, dec.DecodePayloads(bytes.NewReader(file), func(p *Payload) error {
+		got = append(got, p)
+		return nil
+	}))
+	require.Len(t, got, len(payloads))
+	for _, p := range got {
+		require.Equal(t, ci, p.Camera)
+	}
+
+	t.Run("firmware", func(t *testing.T) {
+		udta := &mp4.UdtaBox{}
+		udta.AddChild(testBox(t, udtaFirmware, []byte("HD5.02.02.00.00\x00")))
+		ci, err := dec.DecodeCameraInfo(bytes.NewReader(testMP4(t, payloads, 1001, udta)))
+		require.NoError(t, err)
+		require.NotNil(t, ci)
+		require.Equal(t, "HD5.02.02.00.00", ci.Firmware)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		udta := &mp4.UdtaBox{}
+		udta.AddChild(testBox(t, udtaGPMF, []byte{'M', 'I', 'N', 'F', 'c', 1, 0, 100}))
+		file := testMP4(t, payloads, 1001, udta)
+
+		_, err := dec.DecodeCameraInfo(bytes.NewReader(file))
+		require.Error(t, err)
+
+		var errs []*DecodeError
+		for _, dec := range []*Decoder{dec, NewDecoder(Lenient(func(err *DecodeError) {
+			errs = append(errs, err)
+		}))} {
+			var n int
+			require.NoError(t, dec.DecodePayloads(bytes.NewReader(file), func(p *Payload) error {
+				require.Nil(t, p.Camera)
+				n++
+				return nil
+			}))
+			require.Equal(t, len(payloads), n)
+		}
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0], ErrCameraInfo)
+		require.Equal(t, -1, errs[0].Chunk)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		ci, err := dec.DecodeCameraInfo(bytes.NewReader(testMP4(t, payloads, 1001)))
+		require.NoError(t, err)
+		require.Nil(t, ci)
+	})
+}
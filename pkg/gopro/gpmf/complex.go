@@ -0,0 +1,276 @@
+package gpmf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StructData represents complex data decoded using its type definition.
+type StructData []Struct
+
+// offsets implements offseter.
+func (d StructData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// floats returns the fields of all samples flattened into a single
+// slice of float64, for use by parsers of numeric complex data.
+func (d StructData) floats() ([]float64, error) {
+	var vals []float64
+	for _, s := range d {
+		for i, f := range s.Fields {
+			v, err := floatSlice(f)
+			if err != nil {
+				return nil, fmt.Errorf("field %d: %w", i, err)
+			}
+			vals = append(vals, v...)
+		}
+	}
+
+	return vals, nil
+}
+
+// Struct represents a single sample of complex data.
+type Struct struct {
+	// Fields are the values of each field in the type definition.
+	// Numeric fields have their native type unless scaled in which
+	// case they are float64, arrays are slices of the field type and
+	// character arrays are strings.
+	Fields []any
+	Offset time.Duration
+}
+
+// TypeField represents a single field of a TypeDef.
+type TypeField struct {
+	// Type is the type of the field.
+	Type Type
+
+	// Count is the number of values in the field, greater than one for arrays.
+	Count int
+}
+
+// TypeDef represents a complex structure definition as specified
+// by a TYPE element, for example "Lffff" or "f[8]L".
+// https://github.com/gopro/gpmf-parser#complex-structures
+type TypeDef []TypeField
+
+// ParseTypeDef parses def returning the TypeDef it represents.
+func ParseTypeDef(def string) (TypeDef, error) {
+	def = strings.TrimRight(def, "\x00")
+	td := make(TypeDef, 0, len(def))
+	for i := 0; i < len(def); i++ {
+		f := TypeField{Type: Type(def[i]), Count: 1}
+		if typeFieldSize(f.Type) == 0 {
+			return nil, fmt.Errorf("type def %q: unsupported type %q at %d", def, def[i], i)
+		}
+
+		if i+1 < len(def) && def[i+1] == '[' {
+			end := strings.IndexByte(def[i+1:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("type def %q: unterminated array at %d", def, i+1)
+			}
+
+			n, err := strconv.Atoi(def[i+2 : i+1+end])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("type def %q: invalid array size at %d", def, i+2)
+			}
+
+			f.Count = n
+			i += end + 1
+		}
+
+		td = append(td, f)
+	}
+
+	if len(td) == 0 {
+		return nil, fmt.Errorf("type def %q: no fields", def)
+	}
+
+	return td, nil
+}
+
+// Size returns the size in bytes of a single structure.
+func (d TypeDef) Size() int {
+	var size int
+	for _, f := range d {
+		size += typeFieldSize(f.Type) * f.Count
+	}
+
+	return size
+}
+
+// typeFieldSize returns the size of t when used in a type definition
+// or 0 if t isn't supported.
+func typeFieldSize(t Type) int {
+	switch t { //nolint: exhaustive
+	case Int8, Uint8, String:
+		return 1
+	case Int16, Uint16:
+		return 2
+	case Int32, Uint32, Float32, FourCC, Q32:
+		return 4
+	case Int64, Uint64, Float64, Q64:
+		return 8
+	case GUID, Date:
+		return 16
+	default:
+		return 0
+	}
+}
+
+// decode decodes a single structure from raw applying scale if set.
+func (d TypeDef) decode(raw []byte, scale Scale) (Struct, error) {
+	s := Struct{Fields: make([]any, len(d))}
+	var idx int
+	for i, f := range d {
+		size := typeFieldSize(f.Type)
+		n := size * f.Count
+		v, err := decodeField(f, raw[:n])
+		if err != nil {
+			return s, err
+		}
+
+		if scale != nil && f.Type != String && f.Type != FourCC && f.Type != GUID && f.Type != Date {
+			vals, err := floatSlice(v)
+			if err != nil {
+				return s, err
+			}
+
+			for j := range vals {
+				vals[j] /= scale[(idx+j)%len(scale)]
+			}
+
+			if f.Count == 1 {
+				v = vals[0]
+			} else {
+				v = vals
+			}
+		}
+
+		s.Fields[i] = v
+		raw = raw[n:]
+		idx += f.Count
+	}
+
+	return s, nil
+}
+
+// decodeField returns the value of field f from raw.
+func decodeField(f TypeField, raw []byte) (any, error) { //nolint: cyclop
+	switch f.Type { //nolint: exhaustive
+	case String:
+		return strings.TrimRight(string(raw), "\x00"), nil
+	case FourCC, GUID:
+		return fieldValues(f, raw, 0, func(b []byte) string { return strings.TrimRight(string(b), "\x00") }), nil
+	case Int8:
+		return fieldValues(f, raw, 1, func(b []byte) int8 { return int8(b[0]) }), nil //nolint: gosec
+	case Uint8:
+		return fieldValues(f, raw, 1, func(b []byte) uint8 { return b[0] }), nil
+	case Int16:
+		return fieldValues(f, raw, 2, func(b []byte) int16 { return int16(byteOrder.Uint16(b)) }), nil //nolint: gosec
+	case Uint16:
+		return fieldValues(f, raw, 2, byteOrder.Uint16), nil
+	case Int32:
+		return fieldValues(f, raw, 4, func(b []byte) int32 { return int32(byteOrder.Uint32(b)) }), nil //nolint: gosec
+	case Uint32:
+		return fieldValues(f, raw, 4, byteOrder.Uint32), nil
+	case Float32:
+		return fieldValues(f, raw, 4, func(b []byte) float32 { return math.Float32frombits(byteOrder.Uint32(b)) }), nil
+	case Q32:
+		return fieldValues(f, raw, 4, func(b []byte) Int16_16 { return Int16_16(byteOrder.Uint32(b)) }), nil //nolint: gosec
+	case Int64:
+		return fieldValues(f, raw, 8, func(b []byte) int64 { return int64(byteOrder.Uint64(b)) }), nil //nolint: gosec
+	case Uint64:
+		return fieldValues(f, raw, 8, byteOrder.Uint64), nil
+	case Float64:
+		return fieldValues(f, raw, 8, func(b []byte) float64 { return math.Float64frombits(byteOrder.Uint64(b)) }), nil
+	case Q64:
+		return fieldValues(f, raw, 8, func(b []byte) Int32_32 { return Int32_32(byteOrder.Uint64(b)) }), nil //nolint: gosec
+	case Date:
+		vals := make([]time.Time, f.Count)
+		for i := range vals {
+			date := string(raw[i*16 : (i+1)*16])
+			t, err := time.Parse(dateFormat, date)
+			if err != nil {
+				return nil, fmt.Errorf("parse date %q: %w", date, err)
+			}
+			vals[i] = t
+		}
+
+		if f.Count == 1 {
+			return vals[0], nil
+		}
+
+		return vals, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", f.Type)
+	}
+}
+
+// fieldValues returns the values of f from raw using fn, returning
+// a single value if f isn't an array.
+// If size is 0 the size of the f.Type is used.
+func fieldValues[T any](f TypeField, raw []byte, size int, fn func([]byte) T) any {
+	if size == 0 {
+		size = typeFieldSize(f.Type)
+	}
+
+	if f.Count == 1 {
+		return fn(raw)
+	}
+
+	vals := make([]T, f.Count)
+	for i := range vals {
+		vals[i] = fn(raw[i*size:])
+	}
+
+	return vals
+}
+
+// formatComplex stores the Data decoded using the type definition
+// from the parent, leaving it unset if there is none or it can't be
+// used to decode the data, so an unknown or unexpected structure
+// doesn't prevent reading the rest of the data.
+func (e *Element) formatComplex() error {
+	scale := e.parent.scale
+	e.parent.scale = nil
+
+	v, ok := e.parent.Metadata[friendlyName(KeyTypeDef)]
+	if !ok || e.Header.Count == 0 {
+		// Opaque data or nothing to decode.
+		return nil
+	}
+
+	def, ok := v.(string)
+	if !ok {
+		return nil
+	}
+
+	td, err := ParseTypeDef(def)
+	if err != nil {
+		return nil //nolint: nilerr // Unsupported types are left undecoded.
+	}
+
+	size := int(e.Header.Size)
+	if td.Size() != size {
+		return nil
+	}
+
+	d := make(StructData, e.Header.Count)
+	for i := range d {
+		if d[i], err = td.decode(e.raw[i*size:(i+1)*size], scale); err != nil {
+			return nil //nolint: nilerr // Unsupported types are left undecoded.
+		}
+	}
+
+	e.Data = d
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package gpmf
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTypeDef(t *testing.T) {
+	tests := []struct {
+		def      string
+		expected TypeDef
+		size     int
+		err      bool
+	}{
+		{
+			def:      "Lffff",
+			expected: TypeDef{{Uint32, 1}, {Float32, 1}, {Float32, 1}, {Float32, 1}, {Float32, 1}},
+			size:     20,
+		},
+		{
+			def:      "f[8]L",
+			expected: TypeDef{{Float32, 8}, {Uint32, 1}},
+			size:     36,
+		},
+		{
+			def:      "c[4]Bs[2]\x00",
+			expected: TypeDef{{String, 4}, {Uint8, 1}, {Int16, 2}},
+			size:     9,
+		},
+		{def: "", err: true},
+		{def: "f[8", err: true},
+		{def: "f[0]", err: true},
+		{def: "f?", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.def, func(t *testing.T) {
+			td, err := ParseTypeDef(tc.def)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, td)
+			require.Equal(t, tc.size, td.Size())
+		})
+	}
+}
+
+func TestComplex(t *testing.T) {
+	def := "c[4]Ss[2]f"
+	raw := []byte{
+		'a', 'b', 'c', 0, 0x00, 0x64, 0xff, 0x9c, 0x00, 0xc8, 0x3f, 0xc0, 0x00, 0x00,
+		'x', 'y', 'z', 'w', 0x00, 0x0a, 0x00, 0x01, 0xff, 0xff, 0xbf, 0x80, 0x00, 0x00,
+	}
+	typ := klv(KeyTypeDef, String, 1, uint16(len(def)), []byte(def))
+	data := klv("TEST", Complex, 14, 2, raw)
+
+	read := func(t *testing.T, elems ...[]byte) StructData {
+		t.Helper()
+		b := bytes.Join(elems, nil)
+		strm := klv(KeyStream, Nested, 1, uint16(len(b)), b)
+		res, err := NewReader().Read(bytes.NewReader(strm))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		d, ok := res[0].Nested[len(elems)-1].Data.(StructData)
+		require.True(t, ok)
+		return d
+	}
+
+	t.Run("unscaled", func(t *testing.T) {
+		require.Equal(t, StructData{
+			{Fields: []any{"abc", uint16(100), []int16{-100, 200}, float32(1.5)}},
+			{Fields: []any{"xyzw", uint16(10), []int16{1, -1}, float32(-1)}},
+		}, read(t, typ, data))
+	})
+
+	t.Run("scaled", func(t *testing.T) {
+		// Scale is indexed by expanded field so c[4] uses the first four.
+		scal := klv(KeyScale, Int16, 2, 5, []byte{0, 1, 0, 10, 0, 2, 0, 4, 0, 5})
+		require.Equal(t, StructData{
+			{Fields: []any{"abc", float64(20), []float64{-100, 20}, float64(0.75)}},
+			{Fields: []any{"xyzw", float64(2), []float64{1, -0.1}, float64(-0.5)}},
+		}, read(t, typ, scal, data))
+	})
+
+	t.Run("opaque", func(t *testing.T) {
+		strm := klv(KeyStream, Nested, 1, uint16(len(data)), data)
+		res, err := NewReader().Read(bytes.NewReader(strm))
+		require.NoError(t, err)
+		require.Nil(t, res[0].Nested[0].Data)
+	})
+
+	// Type definitions which can't decode the data leave it undecoded.
+	for name, def := range map[string]string{
+		"size-mismatch": "Lff",
+		"invalid-type":  "c[4]S?f",
+	} {
+		t.Run(name, func(t *testing.T) {
+			bad := klv(KeyTypeDef, String, 1, uint16(len(def)), []byte(def))
+			b := append(bad, data...) //nolint: gocritic
+			strm := klv(KeyStream, Nested, 1, uint16(len(b)), b)
+			res, err := NewReader().Read(bytes.NewReader(strm))
+			require.NoError(t, err)
+			require.Len(t, res[0].Nested, 2)
+			require.Nil(t, res[0].Nested[1].Data)
+		})
+	}
+}
+
+func TestFace(t *testing.T) {
+	f, err := os.Open("../../../test/hero6.raw")
+	require.NoError(t, err)
+	defer f.Close() //nolint: errcheck
+
+	data, err := NewReader().Read(f)
+	require.NoError(t, err)
+
+	var faces []Face6
+	require.NoError(t, Walk(data, func(e *Element) error {
+		if v, ok := e.Data.([]Face6); ok {
+			faces = append(faces, v...)
+		}
+		return nil
+	}))
+
+	require.NotEmpty(t, faces)
+	for _, v := range faces {
+		require.False(t, math.IsNaN(float64(v.X)))
+		require.InDelta(t, 0.5, v.X, 0.5)
+		require.InDelta(t, 0.5, v.Y, 0.5)
+	}
+
+	t.Run("scaled", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			def      string
+			scale    []int16
+			raw      []byte
+			expected any
+		}{
+			{
+				name:  "hero6",
+				def:   faceDefHero6,
+				scale: []int16{1, 2, 2, 2, 2},
+				raw:   be(t, uint32(7), float32(0.5), float32(0.25), float32(0.125), float32(1)),
+				expected: []Face6{
+					{ID: 7, X: 0.25, Y: 0.125, Width: 0.0625, Height: 0.5},
+				},
+			},
+			{
+				name:  "hero10",
+				def:   faceDefHero10,
+				scale: []int16{1, 1, 1, 2, 2, 2, 2, 1, 1},
+				raw:   be(t, uint8(4), uint8(90), uint16(3), uint16(200), uint16(400), uint16(100), uint16(50), uint8(10), uint8(5)),
+				expected: []Face10{
+					{Version: 4, Confidence: 90, ID: 3, X: 100, Y: 200, Width: 50, Height: 25, Smile: 10, Blink: 5},
+				},
+			},
+		}
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				typ := klv(KeyTypeDef, String, 1, uint16(len(tc.def)), []byte(tc.def))
+				scal := klv(KeyScale, Int16, 2, uint16(len(tc.scale)), be(t, tc.scale))
+				face := klv(KeyFace, Complex, byte(len(tc.raw)), 1, tc.raw)
+				b := bytes.Join([][]byte{typ, scal, face}, nil)
+				strm := klv(KeyStream, Nested, 1, uint16(len(b)), b)
+				res, err := NewReader().Read(bytes.NewReader(strm))
+				require.NoError(t, err)
+				require.Len(t, res, 1)
+				require.Len(t, res[0].Nested, 3)
+				require.Equal(t, tc.expected, res[0].Nested[2].Data)
+			})
+		}
+	})
+}
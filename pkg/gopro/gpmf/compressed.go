@@ -0,0 +1,331 @@
+package gpmf
+
+import (
+	"fmt"
+)
+
+const (
+	// compressedHeaderSize is the size of the uncompressed type, size
+	// and repeat header which prefixes compressed data.
+	compressedHeaderSize = 4
+
+	// maxCodeSize is the maximum size in bits of a compression code.
+	maxCodeSize = 16
+)
+
+// codeKind represents the kind of a compression code.
+type codeKind byte
+
+const (
+	// codeDelta is a code for the difference from the previous sample.
+	codeDelta codeKind = iota
+
+	// codeZeros is a code for a run of unchanged samples.
+	codeZeros
+
+	// codeEscape is a code which is followed by a raw delta.
+	codeEscape
+
+	// codeEnd is a code which marks the end of a channel.
+	codeEnd
+)
+
+// huffCode represents a single entry in the compression code book.
+type huffCode struct {
+	// size is the size of the code in bits.
+	size uint8
+
+	// bits is the code right justified.
+	bits uint16
+
+	// kind is the kind of the code.
+	kind codeKind
+
+	// value is the magnitude of a delta or length of a run of zeros.
+	value uint16
+}
+
+var (
+	// huffCodes is the compression code book.
+	// Non zero deltas are followed by a single sign bit, set for negative.
+	// See GPMF_bitstream.h in https://github.com/gopro/gpmf-parser.
+	huffCodes = []huffCode{
+		{size: 1, bits: 0b0, kind: codeDelta, value: 0},
+		{size: 2, bits: 0b10, kind: codeDelta, value: 1},
+		{size: 4, bits: 0b1100, kind: codeDelta, value: 2},
+		{size: 5, bits: 0b11011, kind: codeDelta, value: 3},
+		{size: 5, bits: 0b11101, kind: codeDelta, value: 4},
+		{size: 6, bits: 0b110100, kind: codeDelta, value: 5},
+		{size: 6, bits: 0b110101, kind: codeDelta, value: 6},
+		{size: 6, bits: 0b111110, kind: codeDelta, value: 7},
+		{size: 7, bits: 0b1110000, kind: codeDelta, value: 8},
+		{size: 7, bits: 0b1110011, kind: codeDelta, value: 9},
+		{size: 7, bits: 0b1111000, kind: codeDelta, value: 10},
+		{size: 7, bits: 0b1111001, kind: codeDelta, value: 11},
+		{size: 7, bits: 0b1111011, kind: codeDelta, value: 12},
+		{size: 8, bits: 0b11100100, kind: codeDelta, value: 13},
+		{size: 8, bits: 0b11100101, kind: codeDelta, value: 14},
+		{size: 8, bits: 0b11110100, kind: codeDelta, value: 15},
+		{size: 9, bits: 0b111000100, kind: codeDelta, value: 16},
+		{size: 9, bits: 0b111000101, kind: codeDelta, value: 17},
+		// Zero runs, escape and end share the 0b111111 prefix
+		// followed by a unary count of zero bits.
+		{size: 7, bits: 0b1111111, kind: codeZeros, value: 16},
+		{size: 8, bits: 0b11111101, kind: codeZeros, value: 32},
+		{size: 9, bits: 0b111111001, kind: codeZeros, value: 64},
+		{size: 10, bits: 0b1111110001, kind: codeZeros, value: 128},
+		{size: 16, bits: 0b1111110000000000, kind: codeEscape},
+		{size: 16, bits: 0b1111110000000001, kind: codeEnd},
+	}
+
+	// huffLookup is huffCodes indexed by size and then bits.
+	huffLookup = newHuffLookup(huffCodes)
+)
+
+// newHuffLookup returns codes indexed by size and then bits.
+func newHuffLookup(codes []huffCode) [maxCodeSize + 1]map[uint16]huffCode {
+	var l [maxCodeSize + 1]map[uint16]huffCode
+	for _, c := range codes {
+		if l[c.size] == nil {
+			l[c.size] = make(map[uint16]huffCode)
+		}
+		l[c.size][c.bits] = c
+	}
+
+	return l
+}
+
+// bitReader reads most significant bit first from a byte slice.
+type bitReader struct {
+	buf []byte
+	pos int
+}
+
+// readBits reads n bits and returns them right justified.
+func (b *bitReader) readBits(n int) (uint64, error) {
+	if b.pos+n > len(b.buf)*8 {
+		return 0, fmt.Errorf("compressed: read %d bits at %d: end of data", n, b.pos)
+	}
+
+	var v uint64
+	for range n {
+		bit := b.buf[b.pos/8] >> (7 - b.pos%8) & 1
+		v = v<<1 | uint64(bit)
+		b.pos++
+	}
+
+	return v, nil
+}
+
+// readCode reads and returns the next code.
+func (b *bitReader) readCode() (huffCode, error) {
+	var bits uint16
+	for size := 1; size <= maxCodeSize; size++ {
+		v, err := b.readBits(1)
+		if err != nil {
+			return huffCode{}, err
+		}
+
+		bits = bits<<1 | uint16(v)
+		if c, ok := huffLookup[size][bits]; ok {
+			return c, nil
+		}
+	}
+
+	return huffCode{}, fmt.Errorf("compressed: invalid code 0x%04x at %d", bits, b.pos)
+}
+
+// signed returns v which is n bits wide as a signed value.
+func signed(v uint64, n int) int64 {
+	shift := 64 - n
+	return int64(v<<shift) >> shift //nolint: gosec
+}
+
+// quantize returns the quantization value of each of the channels
+// from metadata of e. A single value applies to every channel.
+func (e *Element) quantize(channels int) ([]int64, error) {
+	quant := make([]int64, channels)
+	v, ok := e.parent.Metadata[friendlyName(KeyQuantize)]
+	if !ok {
+		for i := range quant {
+			quant[i] = 1
+		}
+		return quant, nil
+	}
+
+	q, err := floatSlice(v)
+	if err != nil {
+		return nil, fmt.Errorf("compressed: quantize: %w", err)
+	} else if len(q) != 1 && len(q) != channels {
+		return nil, fmt.Errorf("compressed: quantize: %d values for %d channels", len(q), channels)
+	}
+
+	for i := range quant {
+		f := q[min(i, len(q)-1)]
+		if f < 1 {
+			return nil, fmt.Errorf("compressed: quantize: invalid value %v", v)
+		}
+		quant[i] = int64(f)
+	}
+
+	return quant, nil
+}
+
+// decompress replaces the compressed data of e with the
+// uncompressed data and header it represents.
+// Each channel is encoded in turn as its first raw value followed
+// by codes for the quantized difference to the previous sample.
+func (e *Element) decompress() error {
+	if len(e.raw) < compressedHeaderSize {
+		return fmt.Errorf("compressed: data too short %d", len(e.raw))
+	}
+
+	h := e.Header
+	h.Type = Type(e.raw[0])
+	h.Size = e.raw[1]
+	h.Count = byteOrder.Uint16(e.raw[2:])
+
+	d := &decompressor{
+		br:       &bitReader{buf: e.raw[compressedHeaderSize:]},
+		samples:  int(h.Count),
+		isSigned: h.Type == Int8 || h.Type == Int16 || h.Type == Int32,
+	}
+
+	switch h.Type { //nolint: exhaustive
+	case Int8, Uint8:
+		d.width = 1
+	case Int16, Uint16:
+		d.width = 2
+	case Int32, Uint32:
+		d.width = 4
+	default:
+		return fmt.Errorf("compressed: key %q: unsupported type %s", h.FourCC(), h.Type)
+	}
+
+	if h.Size == 0 || int(h.Size)%d.width != 0 {
+		return fmt.Errorf("compressed: key %q: invalid size %d for type %s", h.FourCC(), h.Size, h.Type)
+	}
+
+	d.channels = int(h.Size) / d.width
+	var err error
+	if d.quant, err = e.quantize(d.channels); err != nil {
+		return err
+	}
+
+	d.raw = make([]byte, int(h.Size)*d.samples)
+	for c := range d.channels {
+		if err := d.channel(c); err != nil {
+			return fmt.Errorf("compressed: key %q channel %d: %w", h.FourCC(), c, err)
+		}
+	}
+
+	e.Header = h
+	e.raw = d.raw
+	e.size = int64(len(d.raw))
+
+	return nil
+}
+
+// decompressor decompresses the channels of an element.
+type decompressor struct {
+	br       *bitReader
+	raw      []byte
+	channels int
+	samples  int
+	width    int
+	quant    []int64
+	isSigned bool
+}
+
+// put stores v as sample idx of channel in raw.
+func (d *decompressor) put(channel, idx int, v int64) {
+	off := (idx*d.channels + channel) * d.width
+	switch d.width {
+	case 1:
+		d.raw[off] = byte(v)
+	case 2:
+		byteOrder.PutUint16(d.raw[off:], uint16(v)) //nolint: gosec
+	default:
+		byteOrder.PutUint32(d.raw[off:], uint32(v)) //nolint: gosec
+	}
+}
+
+// readRaw reads a raw value of the channel width.
+func (d *decompressor) readRaw() (int64, error) {
+	bits := d.width * 8
+	v, err := d.br.readBits(bits)
+	if err != nil {
+		return 0, err
+	}
+
+	if d.isSigned {
+		return signed(v, bits), nil
+	}
+
+	return int64(v), nil //nolint: gosec
+}
+
+// channel decompresses a single channel into raw.
+func (d *decompressor) channel(channel int) error {
+	if d.samples == 0 {
+		return nil
+	}
+
+	last, err := d.readRaw()
+	if err != nil {
+		return err
+	}
+
+	d.put(channel, 0, last)
+	idx := 1
+	for {
+		c, err := d.br.readCode()
+		if err != nil {
+			return err
+		}
+
+		switch c.kind {
+		case codeEnd:
+			if idx != d.samples {
+				return fmt.Errorf("end after %d of %d samples", idx, d.samples)
+			}
+			return nil
+		case codeZeros:
+			if idx+int(c.value) > d.samples {
+				return fmt.Errorf("zero run %d exceeds %d samples", c.value, d.samples)
+			}
+			for range c.value {
+				d.put(channel, idx, last)
+				idx++
+			}
+			continue
+		case codeEscape:
+			// Escaped deltas are raw but signed regardless of the type.
+			v, err := d.br.readBits(d.width * 8)
+			if err != nil {
+				return err
+			}
+			last += signed(v, d.width*8) * d.quant[channel]
+		case codeDelta:
+			delta := int64(c.value)
+			if delta != 0 {
+				sign, err := d.br.readBits(1)
+				if err != nil {
+					return err
+				}
+
+				if sign == 1 {
+					delta = -delta
+				}
+			}
+			last += delta * d.quant[channel]
+		}
+
+		if idx >= d.samples {
+			return fmt.Errorf("more than %d samples", d.samples)
+		}
+
+		d.put(channel, idx, last)
+		idx++
+	}
+}
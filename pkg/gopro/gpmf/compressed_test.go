@@ -0,0 +1,224 @@
+package gpmf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bitWriter writes most significant bit first.
+type bitWriter struct {
+	buf []byte
+	pos int
+}
+
+func (b *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if b.pos%8 == 0 {
+			b.buf = append(b.buf, 0)
+		}
+		b.buf[b.pos/8] |= byte(v>>i&1) << (7 - b.pos%8)
+		b.pos++
+	}
+}
+
+func (b *bitWriter) writeCode(kind codeKind, value uint16) {
+	for _, c := range huffCodes {
+		if c.kind == kind && c.value == value {
+			b.writeBits(uint64(c.bits), int(c.size))
+			return
+		}
+	}
+	panic("no code")
+}
+
+// maxDelta returns the largest delta with a code in huffCodes.
+func maxDelta() int16 {
+	var m uint16
+	for _, c := range huffCodes {
+		if c.kind == codeDelta {
+			m = max(m, c.value)
+		}
+	}
+
+	return int16(m) //nolint: gosec
+}
+
+// compressInt16s compresses samples of channels int16 values using
+// the quantize value of each channel.
+func compressInt16s(t *testing.T, samples [][]int16, quant ...int16) []byte {
+	t.Helper()
+	channels := len(samples[0])
+	bw := &bitWriter{}
+	for c := range channels {
+		quant := quant[min(c, len(quant)-1)]
+		last := samples[0][c]
+		bw.writeBits(uint64(uint16(last)), 16)
+		var zeros uint16
+		flush := func() {
+			for _, run := range []uint16{128, 64, 32, 16} {
+				for zeros >= run {
+					bw.writeCode(codeZeros, run)
+					zeros -= run
+				}
+			}
+			for ; zeros > 0; zeros-- {
+				bw.writeCode(codeDelta, 0)
+			}
+		}
+
+		for _, s := range samples[1:] {
+			delta := (s[c] - last) / quant
+			require.Equal(t, s[c], last+delta*quant, "quantized value")
+			last = s[c]
+			if delta == 0 {
+				zeros++
+				continue
+			}
+
+			flush()
+			mag := delta
+			if mag < 0 {
+				mag = -mag
+			}
+
+			if mag > maxDelta() {
+				bw.writeCode(codeEscape, 0)
+				bw.writeBits(uint64(uint16(delta)), 16)
+				continue
+			}
+
+			bw.writeCode(codeDelta, uint16(mag))
+			if delta < 0 {
+				bw.writeBits(1, 1)
+			} else {
+				bw.writeBits(0, 1)
+			}
+		}
+		flush()
+		bw.writeCode(codeEnd, 0)
+	}
+
+	return bw.buf
+}
+
+// klv returns the encoded klv for key.
+func klv(key string, typ Type, size byte, count uint16, data []byte) []byte {
+	buf := []byte(key)
+	buf = append(buf, byte(typ), size, byte(count>>8), byte(count))
+	buf = append(buf, data...)
+	for len(buf)%alignment != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func TestCompressed(t *testing.T) {
+	samples := [][]int16{
+		{100, -200, 980},
+		{102, -200, 980},
+		{110, -210, 1000},
+		{110, -210, 1000},
+		{160, -300, 1000},
+	}
+	for range 40 {
+		samples = append(samples, samples[len(samples)-1])
+	}
+	samples = append(samples, []int16{20, 4000, -1000})
+
+	const quant = 2
+	var raw []byte
+	for _, s := range samples {
+		for _, v := range s {
+			raw = append(raw, byte(uint16(v)>>8), byte(v))
+		}
+	}
+
+	compressed := []byte{byte(Int16), 6, 0, byte(len(samples))}
+	compressed = append(compressed, compressInt16s(t, samples, quant)...)
+
+	scal := klv(KeyScale, Int16, 2, 1, []byte{0, 100})
+	quan := klv(KeyQuantize, Uint32, 4, 1, []byte{0, 0, 0, quant})
+	plain := klv(KeyAccel, Int16, 6, uint16(len(samples)), raw)
+	comp := klv(KeyAccel, Compressed, 1, uint16(len(compressed)), compressed)
+
+	read := func(t *testing.T, elems ...[]byte) AccelData {
+		t.Helper()
+		data := bytes.Join(elems, nil)
+		strm := klv(KeyStream, Nested, 1, uint16(len(data)), data)
+		res, err := NewReader().Read(bytes.NewReader(strm))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Len(t, res[0].Nested, len(elems))
+		d, ok := res[0].Nested[len(elems)-1].Data.(AccelData)
+		require.True(t, ok)
+		return d
+	}
+
+	expected := read(t, scal, plain)
+	require.Len(t, expected, len(samples))
+	require.Equal(t, expected, read(t, quan, scal, comp))
+
+	t.Run("per-channel", func(t *testing.T) {
+		compressed := []byte{byte(Int16), 6, 0, byte(len(samples))}
+		compressed = append(compressed, compressInt16s(t, samples, 2, 10, 4)...)
+		quan := klv(KeyQuantize, Uint32, 4, 3, []byte{0, 0, 0, 2, 0, 0, 0, 10, 0, 0, 0, 4})
+		comp := klv(KeyAccel, Compressed, 1, uint16(len(compressed)), compressed)
+		require.Equal(t, expected, read(t, quan, scal, comp))
+	})
+
+	t.Run("invalid-quantize", func(t *testing.T) {
+		quan := klv(KeyQuantize, Uint32, 4, 2, []byte{0, 0, 0, 2, 0, 0, 0, 2})
+		data := bytes.Join([][]byte{quan, scal, comp}, nil)
+		strm := klv(KeyStream, Nested, 1, uint16(len(data)), data)
+		_, err := NewReader().Read(bytes.NewReader(strm))
+		require.Error(t, err)
+	})
+}
+
+func TestCompressedBitstream(t *testing.T) {
+	// Hand assembled so it doesn't depend on huffCodes.
+	stream := strings.Join([]string{
+		"0000000000010000", // Raw first value 16.
+		"10" + "0",         // +1 and sign.
+		"1100" + "1",       // -2 and sign.
+		"1111111",          // 16 zeros.
+		"11100100" + "1",   // -13 and sign.
+		"111000101" + "0",  // +17 and sign.
+		"1111110000000000", // Escape.
+		"1111111100000000", // Raw delta -256.
+		"1111110000000001", // End.
+	}, "")
+	bw := &bitWriter{}
+	for _, b := range stream {
+		bw.writeBits(uint64(b-'0'), 1)
+	}
+
+	compressed := append([]byte{byte(Int16), 2, 0, 22}, bw.buf...)
+	data := klv("TEST", Compressed, 1, uint16(len(compressed)), compressed)
+	strm := klv(KeyStream, Nested, 1, uint16(len(data)), data)
+	res, err := NewReader().Read(bytes.NewReader(strm))
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Len(t, res[0].Nested, 1)
+
+	expected := []int16{16, 17, 15}
+	for range 16 {
+		expected = append(expected, 15)
+	}
+	expected = append(expected, 2, 19, -237)
+	require.Equal(t, expected, res[0].Nested[0].Data)
+
+	t.Run("prefix-free", func(t *testing.T) {
+		for i, a := range huffCodes {
+			for j, b := range huffCodes {
+				if i == j || a.size > b.size {
+					continue
+				}
+				require.NotEqual(t, a.bits, b.bits>>(b.size-a.size), "%0*b prefix of %0*b", a.size, a.bits, b.size, b.bits)
+			}
+		}
+	})
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Eyevinn/mp4ff/mp4"
@@ -17,23 +19,375 @@ const (
 
 // Decoder is a GoPro mp4 metadata decoder.
 type Decoder struct {
-	reader *Reader
+	reader  *Reader
+	workers int
+	lenient bool
+	onError func(err *DecodeError)
+}
+
+// DecoderOption is an option for a Decoder.
+type DecoderOption func(*Decoder)
+
+// WithReader sets the Reader used to read metadata, for example
+// to use a Reader configured with MountRotation.
+// Default: NewReader().
+func WithReader(r *Reader) DecoderOption {
+	return func(d *Decoder) {
+		d.reader = r
+	}
+}
+
+// Workers sets the number of goroutines used to read chunks concurrently
+// when the mp4 stream implements io.ReaderAt, as *os.File does. Payloads
+// are still passed to PayloadFuncs in order.
+// Default: 1, chunks are read sequentially.
+func Workers(n int) DecoderOption {
+	return func(d *Decoder) {
+		d.workers = n
+	}
+}
+
+// Lenient enables lenient mode, in which a chunk that can't be read,
+// for example due to corrupt or truncated data, is skipped and decoding
+// continues with the next chunk instead of stopping. A chapter whose mp4
+// structure can't be decoded or which has no metadata is skipped in the
+// same way. Times of later chapters allow for the duration of a skipped
+// chapter from its mp4 headers. If it's too corrupt for that its
+// DecodeError End is equal to Start and times of later chapters, other
+// than those from STMP, are early by its duration. If fn is not nil
+// it's called with the details of each skipped chunk or chapter, and of
+// camera information which can't be read, see ErrCameraInfo.
+// Errors returned by PayloadFuncs still stop decoding.
+// Default: disabled, the first error stops decoding.
+func Lenient(fn func(err *DecodeError)) DecoderOption {
+	return func(d *Decoder) {
+		d.lenient = true
+		d.onError = fn
+	}
 }
 
 // NewDecoder returns a new Decoder.
-func NewDecoder() *Decoder {
-	return &Decoder{
-		reader: NewReader(),
+func NewDecoder(options ...DecoderOption) *Decoder {
+	d := &Decoder{
+		reader:  NewReader(),
+		workers: 1,
+	}
+
+	for _, o := range options {
+		o(d)
+	}
+
+	return d
+}
+
+var (
+	// ErrStop is used as a return value from PayloadFuncs to
+	// indicate that decoding should stop.
+	// It is not returned as an error by any function.
+	ErrStop = errors.New("stop decoding")
+
+	// ErrCameraInfo is wrapped by the DecodeError reported when the
+	// camera information of a chapter can't be read. Camera information
+	// is optional so decoding always continues, with Payload.Camera nil
+	// for the chapter.
+	ErrCameraInfo = errors.New("camera info")
+)
+
+// DecodeError represents a chunk or chapter skipped in lenient mode.
+type DecodeError struct {
+	// Chapter is the index of the chapter.
+	Chapter int
+
+	// Chunk is the index of the chunk in the chapters metadata track,
+	// -1 if the whole chapter was skipped or the error is ErrCameraInfo.
+	Chunk int
+
+	// Offset is the offset of the chunk in the chapters mp4 stream.
+	Offset int64
+
+	// Start is the decode time of the start of the chunk.
+	Start time.Duration
+
+	// End is the decode time of the end of the chunk.
+	End time.Duration
+
+	// Err is the underlying error, a *ReadError if the chunk
+	// data is corrupt.
+	Err error
+}
+
+// Error implements error.
+func (e *DecodeError) Error() string {
+	if e.Chunk < 0 {
+		return fmt.Sprintf("decode: chapter %d: %v", e.Chapter+1, e.Err)
 	}
+
+	return fmt.Sprintf("decode: chapter %d: chunk %d at offset %d (%s - %s): %v",
+		e.Chapter+1,
+		e.Chunk,
+		e.Offset,
+		e.Start,
+		e.End,
+		e.Err,
+	)
 }
 
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Payload represents the metadata from a single mp4 chunk.
+type Payload struct {
+	// Start is the decode time of the first sample in the chunk.
+	Start time.Duration
+
+	// End is the decode time of the end of the last sample in the chunk.
+	End time.Duration
+
+	// Elements are the elements decoded from the chunk.
+	Elements []*Element
+
+	// Camera is the camera information of the file containing the
+	// chunk, nil if it has none.
+	Camera *CameraInfo
+}
+
+// Devices returns the devices recorded in the payload.
+func (p *Payload) Devices() []*Device {
+	return Devices(p.Elements)
+}
+
+// PayloadFunc is the type of the function called by DecodePayloads
+// for each Payload.
+type PayloadFunc func(p *Payload) error
+
 // Decode decodes metadata from the mp4 stream in rs.
 func (d *Decoder) Decode(rs io.ReadSeeker) ([]*Element, error) {
-	f, err := mp4.DecodeFile(rs)
+	var data []*Element
+	if err := d.DecodePayloads(rs, func(p *Payload) error {
+		data = append(data, p.Elements...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// DecodeDevices decodes metadata from the mp4 stream in rs returning
+// the devices it contains, with the data of each stream combined
+// across all payloads.
+func (d *Decoder) DecodeDevices(rs io.ReadSeeker) ([]*Device, error) {
+	data, err := d.Decode(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	return Devices(data), nil
+}
+
+// DecodePayloads decodes metadata from the mp4 stream in rs calling fn
+// for each Payload in order. Only the current Payload is held in memory
+// so long recordings can be processed without loading all their metadata.
+// If fn returns ErrStop decoding stops and nil is returned.
+func (d *Decoder) DecodePayloads(rs io.ReadSeeker, fn PayloadFunc) error {
+	return d.DecodeChapters([]io.ReadSeeker{rs}, fn)
+}
+
+// DecodeChapters decodes metadata from the mp4 streams in chapters,
+// which must be the chapters of a single recording in order, calling
+// fn for each Payload in order as DecodePayloads does.
+// The chapters are treated as one continuous timeline so Payload times
+// and data offsets continue from the end of the previous chapter.
+func (d *Decoder) DecodeChapters(chapters []io.ReadSeeker, fn PayloadFunc) error {
+	t := newTiming()
+	for i, rs := range chapters {
+		t.chapter = i
+		if err := d.decodeChapter(rs, t, fn); err != nil {
+			return chapterError(err, i, len(chapters))
+		}
+	}
+
+	return nil
+}
+
+// DecodeFiles decodes metadata from the named mp4 files, which must be
+// the chapters of a single recording in order, as DecodeChapters does.
+// Only one file is open at a time.
+func (d *Decoder) DecodeFiles(fn PayloadFunc, names ...string) error {
+	t := newTiming()
+	for i, name := range names {
+		t.chapter = i
+		if err := d.decodeFile(name, t, fn); err != nil {
+			return chapterError(err, i, len(names))
+		}
+	}
+
+	return nil
+}
+
+// chapterError returns err annotated with the chapter if there is
+// more than one, nil if err is nil or ErrStop.
+func chapterError(err error, idx, chapters int) error {
+	switch {
+	case errors.Is(err, ErrStop):
+		return nil
+	case chapters > 1:
+		return fmt.Errorf("chapter %d: %w", idx+1, err)
+	default:
+		return err
+	}
+}
+
+// DecodeRaw decodes raw GPMF data, as extracted from the metadata
+// track of a mp4, from r calling fn for each Payload in order as
+// DecodePayloads does. Raw data has no timing information so each
+// payload is assumed to have the given duration, which is typically
+// one second for GoPro cameras. A new payload starts each time a
+// device is seen again.
+func (d *Decoder) DecodeRaw(r io.Reader, duration time.Duration, fn PayloadFunc) error {
+	data, err := d.readerOrDefault().Read(r)
+	if err != nil {
+		return fmt.Errorf("decode: raw: %w", err)
+	}
+
+	t := newTiming()
+	var idx uint64
+	emit := func(elems []*Element) error {
+		if len(elems) == 0 {
+			return nil
+		}
+
+		if err := Walk(elems, newOffsetWalker(idx, idx+1, duration, t).walk); err != nil {
+			return fmt.Errorf("decode: raw: offsets: %w", err)
+		}
+
+		p := &Payload{
+			Start:    time.Duration(idx) * duration,   //nolint: gosec
+			End:      time.Duration(idx+1) * duration, //nolint: gosec
+			Elements: elems,
+		}
+		idx++
+
+		return fn(p)
+	}
+
+	var payload []*Element
+	seen := make(map[string]struct{})
+	for _, e := range data {
+		id, _ := e.Metadata[friendlyName(KeyDeviceID)]
+		key := fmt.Sprint(id)
+		if _, ok := seen[key]; ok {
+			if err := emit(payload); errors.Is(err, ErrStop) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			payload = nil
+			clear(seen)
+		}
+
+		seen[key] = struct{}{}
+		payload = append(payload, e)
+	}
+
+	if err := emit(payload); err != nil && !errors.Is(err, ErrStop) {
+		return err
+	}
+
+	return nil
+}
+
+// DecodeCameraInfo decodes the camera information from the mp4 stream
+// in rs. It returns nil if there is none.
+func (d *Decoder) DecodeCameraInfo(rs io.ReadSeeker) (*CameraInfo, error) {
+	f, err := mp4.DecodeFile(rs, mp4.WithDecodeMode(mp4.DecModeLazyMdat))
 	if err != nil {
 		return nil, fmt.Errorf("decode: mp4 %w", err)
 	}
 
+	ci, err := d.cameraInfo(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode: camera info: %w", err)
+	}
+
+	return ci, nil
+}
+
+// readerOrDefault returns the Reader of d or a new Reader if it has
+// none, as is the case for a zero value Decoder.
+func (d *Decoder) readerOrDefault() *Reader {
+	if d.reader == nil {
+		return NewReader()
+	}
+
+	return d.reader
+}
+
+// decodeFile opens and decodes the mp4 file name.
+func (d *Decoder) decodeFile(name string, t *timing, fn PayloadFunc) error {
+	f, err := os.Open(name) //nolint: gosec // Yes it is.
+	if err != nil {
+		return fmt.Errorf("decode: open %w", err)
+	}
+
+	defer f.Close() //nolint: errcheck
+
+	return d.decodeChapter(f, t, fn)
+}
+
+// decodeChapter decodes metadata from the mp4 stream in rs with
+// times offset by the end of the previous chapter as tracked by t.
+func (d *Decoder) decodeChapter(rs io.ReadSeeker, t *timing, fn PayloadFunc) error {
+	f, err := mp4.DecodeFile(rs, mp4.WithDecodeMode(mp4.DecModeLazyMdat))
+	if err != nil {
+		// The duration is unknown so the chapter can't be accounted for.
+		return d.skipChapter(t, 0, fmt.Errorf("decode: mp4 %w", err))
+	} else if f.Moov == nil {
+		return d.skipChapter(t, 0, fmt.Errorf("decode: no moov box found"))
+	}
+
+	ci, err := d.cameraInfo(f)
+	if err != nil && d.onError != nil {
+		d.onError(&DecodeError{
+			Chapter: t.chapter,
+			Chunk:   -1,
+			Start:   t.offset,
+			End:     t.offset,
+			Err:     fmt.Errorf("%w: %w", ErrCameraInfo, err),
+		})
+	}
+
+	i, trak := metadataTrak(f)
+	if trak == nil {
+		return d.skipChapter(t, movieDuration(f.Moov.Mvhd), fmt.Errorf("decode: no metadata for %q found", handlerName))
+	}
+
+	dur := trakDuration(trak)
+	chunks, err := d.chunks(trak.Mdia.Minf.Stbl)
+	if err != nil {
+		return d.skipChapter(t, dur, fmt.Errorf("decode: trak %d: %w", i, err))
+	}
+
+	if err := d.decodeTrak(rs, chunks, trakUnits(trak), t, func(p *Payload) error {
+		p.Camera = ci
+		return fn(p)
+	}); err != nil {
+		if errors.Is(err, ErrStop) {
+			return err
+		}
+		return fmt.Errorf("decode: trak %d: %w", i, err)
+	}
+
+	t.offset += dur
+
+	return nil
+}
+
+// metadataTrak returns the metadata track of f and its index,
+// nil if it has none.
+func metadataTrak(f *mp4.File) (int, *mp4.TrakBox) {
 	for i, trak := range f.Moov.Traks {
 		if trak.Mdia.Hdlr.HandlerType != handlerType {
 			// Not our handler type.
@@ -43,16 +397,46 @@ func (d *Decoder) Decode(rs io.ReadSeeker) ([]*Element, error) {
 			continue
 		}
 
-		units := time.Second / time.Duration(trak.Mdia.Mdhd.Timescale)
-		data, err := d.decodeTrak(rs, trak.Mdia.Minf.Stbl, units)
-		if err != nil {
-			return nil, fmt.Errorf("decode: trak %d: %w", i, err)
-		}
+		return i, trak
+	}
+
+	return -1, nil
+}
+
+// chapterDuration returns the duration of the chapter f, which is
+// that of its metadata track if it has one, so times across chapters
+// are consistent with those of decoded metadata.
+func chapterDuration(f *mp4.File) time.Duration {
+	if _, trak := metadataTrak(f); trak != nil {
+		return trakDuration(trak)
+	}
+
+	return movieDuration(f.Moov.Mvhd)
+}
 
-		return data, nil
+// trakUnits returns the duration of a time unit of trak.
+func trakUnits(trak *mp4.TrakBox) time.Duration {
+	return time.Second / time.Duration(trak.Mdia.Mdhd.Timescale)
+}
+
+// trakDuration returns the total duration of the samples of trak.
+func trakDuration(trak *mp4.TrakBox) time.Duration {
+	var dec uint64
+	stts := trak.Mdia.Minf.Stbl.Stts
+	for i, n := range stts.SampleCount {
+		dec += uint64(n) * uint64(stts.SampleTimeDelta[i])
 	}
 
-	return nil, fmt.Errorf("decode: no metadata for %q found", handlerName)
+	return time.Duration(dec) * trakUnits(trak) //nolint: gosec
+}
+
+// movieDuration returns the duration from mvhd, 0 if unknown.
+func movieDuration(mvhd *mp4.MvhdBox) time.Duration {
+	if mvhd == nil || mvhd.Timescale == 0 {
+		return 0
+	}
+
+	return time.Duration(mvhd.Duration) * time.Second / time.Duration(mvhd.Timescale) //nolint: gosec
 }
 
 // chunkOffsets returns the chunk offsets for stbl.
@@ -72,33 +456,196 @@ func (d *Decoder) chunkOffsets(stbl *mp4.StblBox) ([]uint64, error) {
 	}
 }
 
-// readChunk reads a single chunk from rs and amends it with offset information.
-func (d *Decoder) readChunk(rs io.ReadSeeker,
-	offset, size int64,
-	startDec, endDec uint64,
-	units time.Duration,
-) ([]*Element, error) {
-	if _, err := rs.Seek(offset, io.SeekStart); err != nil {
-		return nil, fmt.Errorf("seek: %w", err)
-	}
+// chunk represents a single chunk of a metadata track.
+type chunk struct {
+	// offset is the offset of the chunk in the file.
+	offset int64
+
+	// size is the size of the chunk in bytes.
+	size int64
 
-	data, err := d.reader.Read(io.LimitReader(rs, size))
+	// start and end are the decode times of the chunk in track units.
+	start, end uint64
+}
+
+// readChunk reads a single chunk from r.
+func (d *Decoder) readChunk(r io.Reader) ([]*Element, error) {
+	data, err := d.readerOrDefault().Read(r)
 	if err != nil {
 		return nil, fmt.Errorf("read: %w", err)
 	}
 
-	if err := Walk(data, newOffsetWalker(startDec, endDec, units).walk); err != nil {
-		return nil, fmt.Errorf("offsets: %w", err)
+	return data, nil
+}
+
+// payload amends the data of c with offset information and calls fn.
+func (d *Decoder) payload(c chunk, data []*Element, units time.Duration, t *timing, fn PayloadFunc) error {
+	if err := Walk(data, newOffsetWalker(c.start, c.end, units, t).walk); err != nil {
+		return fmt.Errorf("offsets: %w", err)
 	}
 
-	return data, nil
+	return fn(&Payload{
+		Start:    t.offset + time.Duration(c.start)*units, //nolint: gosec
+		End:      t.offset + time.Duration(c.end)*units,   //nolint: gosec
+		Elements: data,
+	})
+}
+
+// decodeTrak decodes chunks of a single tracks data from rs calling fn
+// for each. If rs implements io.ReaderAt and d has more than one worker,
+// chunks are read concurrently.
+func (d *Decoder) decodeTrak(rs io.ReadSeeker,
+	chunks []chunk,
+	units time.Duration,
+	t *timing,
+	fn PayloadFunc,
+) error {
+	if ra, ok := rs.(io.ReaderAt); ok && d.workers > 1 {
+		return d.decodeConcurrent(ra, chunks, units, t, fn)
+	}
+
+	for i, c := range chunks {
+		if _, err := rs.Seek(c.offset, io.SeekStart); err != nil {
+			if err := d.skip(t, i, c, units, fmt.Errorf("seek: %w", err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := d.readChunk(io.LimitReader(rs, c.size))
+		if err != nil {
+			if err := d.skip(t, i, c, units, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.payload(c, data, units, t, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// skip returns err unless d is lenient, in which case it reports err
+// for the chunk at idx, or the whole chapter if idx is -1, and
+// returns nil so decoding continues.
+func (d *Decoder) skip(t *timing, idx int, c chunk, units time.Duration, err error) error {
+	if !d.lenient {
+		return err
+	}
+
+	if d.onError != nil {
+		d.onError(&DecodeError{
+			Chapter: t.chapter,
+			Chunk:   idx,
+			Offset:  c.offset,
+			Start:   t.offset + time.Duration(c.start)*units, //nolint: gosec
+			End:     t.offset + time.Duration(c.end)*units,   //nolint: gosec
+			Err:     err,
+		})
+	}
+
+	return nil
+}
+
+// skipChapter is skip for a whole chapter of duration dur, which also
+// advances t by dur so the times of later chapters are unaffected.
+func (d *Decoder) skipChapter(t *timing, dur time.Duration, err error) error {
+	if err := d.skip(t, -1, chunk{end: uint64(dur)}, 1, err); err != nil { //nolint: gosec
+		return err
+	}
+
+	t.offset += dur
+
+	return nil
+}
+
+// chunkResult is the result of reading a chunk.
+type chunkResult struct {
+	data []*Element
+	err  error
+}
+
+// decodeConcurrent reads chunks from ra using d.workers goroutines,
+// calling fn for each in order. Offsets depend on the preceding chunks
+// so they are calculated in order as results are consumed.
+func (d *Decoder) decodeConcurrent(ra io.ReaderAt,
+	chunks []chunk,
+	units time.Duration,
+	t *timing,
+	fn PayloadFunc,
+) error {
+	results := make([]chan chunkResult, len(chunks))
+	for i := range results {
+		results[i] = make(chan chunkResult, 1)
+	}
+
+	// Limit the number of chunks in memory which haven't been consumed.
+	tokens := make(chan struct{}, d.workers*2)
+	jobs := make(chan int)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := range chunks {
+			select {
+			case tokens <- struct{}{}:
+			case <-done:
+				return
+			}
+
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for range d.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				c := chunks[i]
+				data, err := d.readChunk(io.NewSectionReader(ra, c.offset, c.size))
+				results[i] <- chunkResult{data: data, err: err}
+			}
+		}()
+	}
+
+	for i, c := range chunks {
+		res := <-results[i]
+		<-tokens
+		if res.err != nil {
+			if err := d.skip(t, i, c, units, res.err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.payload(c, res.data, units, t, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// decodeTrak decodes all chunks from single tracks data as detailed in stbl from rs.
-func (d *Decoder) decodeTrak(rs io.ReadSeeker, stbl *mp4.StblBox, units time.Duration) ([]*Element, error) {
+// chunks returns the chunks of the track detailed in stbl.
+func (d *Decoder) chunks(stbl *mp4.StblBox) ([]chunk, error) {
 	chunkOffsets, err := d.chunkOffsets(stbl)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("chunks: %w", err)
 	}
 
 	// Chunks contain one or more contiguous samples.
@@ -114,13 +661,13 @@ func (d *Decoder) decodeTrak(rs io.ReadSeeker, stbl *mp4.StblBox, units time.Dur
 	lastSampleNr := stbl.Stsz.GetNrSamples() - 1
 
 	var (
+		chunks             []chunk
 		timeIdx            int
 		dec                uint64
 		chunkNr            uint32
 		firstSampleInChunk uint32 = 1
 	)
 
-	var data []*Element
 	timeNext := stts.SampleCount[timeIdx]
 	dur := stts.SampleTimeDelta[timeIdx]
 	for i, entry := range stsc.Entries {
@@ -135,9 +682,10 @@ func (d *Decoder) decodeTrak(rs io.ReadSeeker, stbl *mp4.StblBox, units time.Dur
 
 		for {
 			nextChunkStart := firstSampleInChunk + chunkLen
-			offset := chunkOffsets[chunkNr-1]
-			start := dec
-			var chunkSize int64
+			c := chunk{
+				offset: int64(chunkOffsets[chunkNr-1]), //nolint: gosec
+				start:  dec,
+			}
 			for s, l := firstSampleInChunk, firstSampleInChunk+chunkLen; s < l; s++ {
 				size := stsz.GetSampleSize(int(s))
 				if s > timeNext {
@@ -146,15 +694,11 @@ func (d *Decoder) decodeTrak(rs io.ReadSeeker, stbl *mp4.StblBox, units time.Dur
 					dur = stts.SampleTimeDelta[timeIdx]
 				}
 				dec += uint64(dur)
-				chunkSize += int64(size)
+				c.size += int64(size)
 			}
+			c.end = dec
+			chunks = append(chunks, c)
 
-			cd, err := d.readChunk(rs, int64(offset), chunkSize, start, dec, units) //nolint: gosec
-			if err != nil {
-				return nil, err
-			}
-
-			data = append(data, cd...)
 			if lastSampleNr < firstSampleInChunk {
 				break
 			}
@@ -167,5 +711,5 @@ func (d *Decoder) decodeTrak(rs io.ReadSeeker, stbl *mp4.StblBox, units time.Dur
 		}
 	}
 
-	return data, nil
+	return chunks, nil
 }
@@ -1,10 +1,15 @@
 package gpmf
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/Eyevinn/mp4ff/mp4"
 	"github.com/stretchr/testify/require"
 )
 
@@ -32,3 +37,300 @@ func TestDecoder(t *testing.T) {
 		})
 	}
 }
+
+// testPayloads returns the top level elements of the raw test file
+// name encoded as individual payloads.
+func testPayloads(t *testing.T, name string) [][]byte {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("../../../test", name+".raw")) //nolint: gosec
+	require.NoError(t, err)
+	defer f.Close() //nolint: errcheck
+
+	data, err := NewReader().Read(f)
+	require.NoError(t, err)
+
+	payloads := make([][]byte, len(data))
+	for i, e := range data {
+		var buf bytes.Buffer
+		require.NoError(t, NewWriter().Write(&buf, []*Element{e}))
+		payloads[i] = buf.Bytes()
+	}
+
+	return payloads
+}
+
+// testMP4 returns a mp4 containing a GoPro metadata track with one
+// chunk per payload each of which has the duration delta in milliseconds.
+// Any boxes are added to the moov box.
+func testMP4(t *testing.T, payloads [][]byte, delta uint32, boxes ...mp4.Box) []byte {
+	t.Helper()
+
+	trak := mp4.CreateEmptyTrak(1, 1000, handlerType, "und")
+	trak.Mdia.Hdlr.Name = "\t" + handlerName
+	stbl := trak.Mdia.Minf.Stbl
+	stbl.Stts.SampleCount = []uint32{uint32(len(payloads))} //nolint: gosec
+	stbl.Stts.SampleTimeDelta = []uint32{delta}
+	require.NoError(t, stbl.Stsc.AddEntry(1, 1, 1))
+
+	mdat := &mp4.MdatBox{}
+	for _, p := range payloads {
+		stbl.Stsz.SampleSize = append(stbl.Stsz.SampleSize, uint32(len(p)))           //nolint: gosec
+		stbl.Stco.ChunkOffset = append(stbl.Stco.ChunkOffset, uint32(len(mdat.Data))) //nolint: gosec
+		mdat.Data = append(mdat.Data, p...)
+	}
+	stbl.Stsz.SampleNumber = uint32(len(payloads)) //nolint: gosec
+
+	moov := mp4.NewMoovBox()
+	moov.AddChild(mp4.CreateMvhd())
+	moov.AddChild(trak)
+	for _, b := range boxes {
+		moov.AddChild(b)
+	}
+
+	ftyp := mp4.CreateFtyp()
+	start := ftyp.Size() + moov.Size() + mdat.HeaderSize()
+	for i := range stbl.Stco.ChunkOffset {
+		stbl.Stco.ChunkOffset[i] += uint32(start) //nolint: gosec
+	}
+
+	var buf bytes.Buffer
+	for _, b := range []mp4.Box{ftyp, moov, mdat} {
+		require.NoError(t, b.Encode(&buf))
+	}
+
+	return buf.Bytes()
+}
+
+func TestDecodePayloads(t *testing.T) {
+	payloads := testPayloads(t, "hero6-multi-chunk")
+	file := testMP4(t, payloads, 1001)
+
+	var got []*Payload
+	dec := NewDecoder()
+	err := dec.DecodePayloads(bytes.NewReader(file), func(p *Payload) error {
+		got = append(got, p)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, got, len(payloads))
+	for i, p := range got {
+		require.Equal(t, time.Duration(i)*1001*time.Millisecond, p.Start)
+		require.Equal(t, time.Duration(i+1)*1001*time.Millisecond, p.End)
+		require.Len(t, p.Elements, 1)
+	}
+
+	data, err := dec.Decode(bytes.NewReader(file))
+	require.NoError(t, err)
+	require.Len(t, data, len(payloads))
+
+	devs, err := dec.DecodeDevices(bytes.NewReader(file))
+	require.NoError(t, err)
+	require.Len(t, devs, 1)
+	require.Len(t, devs[0].Stream(KeyGPS).Data, len(GPSSamples(data)))
+
+	t.Run("workers", func(t *testing.T) {
+		for _, workers := range []int{2, 4, 64} {
+			var res []*Payload
+			err := NewDecoder(Workers(workers)).DecodePayloads(bytes.NewReader(file), func(p *Payload) error {
+				res = append(res, p)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, got, res)
+		}
+	})
+
+	t.Run("workers-error", func(t *testing.T) {
+		truncated := file[:len(file)-len(payloads[len(payloads)-1])/2]
+		var count int
+		err := NewDecoder(Workers(4)).DecodePayloads(bytes.NewReader(truncated), func(_ *Payload) error {
+			count++
+			return nil
+		})
+		require.Error(t, err)
+		require.Equal(t, len(payloads)-1, count)
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		const bad = 5
+		corrupt := bytes.Clone(file)
+		offset := bytes.Index(corrupt, payloads[bad])
+		require.Positive(t, offset)
+		corrupt[offset] = 0xff
+
+		tests := map[string][]byte{
+			"corrupt":   corrupt,
+			"truncated": file[:len(file)-len(payloads[len(payloads)-1])/2],
+		}
+		for name, data := range tests {
+			for _, workers := range []int{1, 4} {
+				var skipped []*DecodeError
+				var res []*Payload
+				dec := NewDecoder(Workers(workers), Lenient(func(err *DecodeError) {
+					skipped = append(skipped, err)
+				}))
+				err := dec.DecodePayloads(bytes.NewReader(data), func(p *Payload) error {
+					res = append(res, p)
+					return nil
+				})
+				require.NoError(t, err, name)
+				require.Len(t, res, len(payloads)-1, name)
+				require.Len(t, skipped, 1, name)
+
+				idx := bad
+				if name == "truncated" {
+					idx = len(payloads) - 1
+				}
+				require.Equal(t, 0, skipped[0].Chapter, name)
+				require.Equal(t, idx, skipped[0].Chunk, name)
+				require.Equal(t, got[idx].Start, skipped[0].Start, name)
+				require.Equal(t, got[idx].End, skipped[0].End, name)
+
+				var re *ReadError
+				require.ErrorAs(t, skipped[0], &re, name)
+				if name == "corrupt" {
+					require.Equal(t, int64(offset), skipped[0].Offset)
+					require.Equal(t, int64(0), re.Offset)
+				}
+			}
+		}
+	})
+
+	t.Run("workers-stop", func(t *testing.T) {
+		var count int
+		err := NewDecoder(Workers(4)).DecodePayloads(bytes.NewReader(file), func(_ *Payload) error {
+			count++
+			if count == 3 {
+				return ErrStop
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		var count int
+		err := dec.DecodePayloads(bytes.NewReader(file), func(_ *Payload) error {
+			count++
+			if count == 3 {
+				return ErrStop
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+	})
+}
+
+func TestDecodeChapters(t *testing.T) {
+	payloads := testPayloads(t, "hero6-multi-chunk")
+	split := len(payloads) / 2
+	chapters := [][]byte{
+		testMP4(t, payloads[:split], 1001),
+		testMP4(t, payloads[split:], 1001),
+	}
+
+	check := func(t *testing.T, got []*Payload) {
+		t.Helper()
+		require.Len(t, got, len(payloads))
+		for i, p := range got {
+			require.Equal(t, time.Duration(i)*1001*time.Millisecond, p.Start)
+			require.Equal(t, time.Duration(i+1)*1001*time.Millisecond, p.End)
+			gps := GPSSamples(p.Elements)
+			require.NotEmpty(t, gps)
+			require.Equal(t, p.Start, gps[0].Offset)
+		}
+	}
+
+	collect := func(got *[]*Payload) PayloadFunc {
+		return func(p *Payload) error {
+			*got = append(*got, p)
+			return nil
+		}
+	}
+
+	dec := NewDecoder()
+	t.Run("readers", func(t *testing.T) {
+		var got []*Payload
+		require.NoError(t, dec.DecodeChapters([]io.ReadSeeker{
+			bytes.NewReader(chapters[0]),
+			bytes.NewReader(chapters[1]),
+		}, collect(&got)))
+		check(t, got)
+	})
+
+	t.Run("files", func(t *testing.T) {
+		dir := t.TempDir()
+		names := make([]string, len(chapters))
+		for i, c := range chapters {
+			names[i] = filepath.Join(dir, fmt.Sprintf("GX%02d0001.MP4", i+1))
+			require.NoError(t, os.WriteFile(names[i], c, 0o600))
+		}
+
+		var got []*Payload
+		require.NoError(t, dec.DecodeFiles(collect(&got), names...))
+		check(t, got)
+
+		err := dec.DecodeFiles(collect(&got), names[0], filepath.Join(dir, "missing.mp4"))
+		require.ErrorContains(t, err, "chapter 2")
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		truncated := chapters[1][:100]
+		err := dec.DecodeChapters([]io.ReadSeeker{
+			bytes.NewReader(chapters[0]),
+			bytes.NewReader(truncated),
+		}, collect(new([]*Payload)))
+		require.ErrorContains(t, err, "chapter 2")
+
+		var skipped []*DecodeError
+		var got []*Payload
+		err = NewDecoder(Lenient(func(err *DecodeError) {
+			skipped = append(skipped, err)
+		})).DecodeChapters([]io.ReadSeeker{
+			bytes.NewReader(chapters[0]),
+			bytes.NewReader(truncated),
+		}, collect(&got))
+		require.NoError(t, err)
+		require.Len(t, got, split)
+		require.Len(t, skipped, 1)
+		require.Equal(t, 1, skipped[0].Chapter)
+		require.Equal(t, -1, skipped[0].Chunk)
+		require.ErrorContains(t, skipped[0], "decode: chapter 2: decode: mp4")
+	})
+
+	t.Run("lenient-no-metadata", func(t *testing.T) {
+		// A chapter without metadata is skipped using its movie duration.
+		f, err := mp4.DecodeFile(bytes.NewReader(chapters[1]))
+		require.NoError(t, err)
+		f.Moov.Traks[0].Mdia.Hdlr.Name = "other"
+		f.Moov.Mvhd.Timescale = 1000
+		f.Moov.Mvhd.Duration = uint64(len(payloads)-split) * 1001 //nolint: gosec
+		var buf bytes.Buffer
+		require.NoError(t, f.Encode(&buf))
+
+		err = dec.DecodeChapters([]io.ReadSeeker{bytes.NewReader(buf.Bytes())}, collect(new([]*Payload)))
+		require.ErrorContains(t, err, "no metadata")
+
+		var skipped []*DecodeError
+		var got []*Payload
+		require.NoError(t, NewDecoder(Lenient(func(err *DecodeError) {
+			skipped = append(skipped, err)
+		})).DecodeChapters([]io.ReadSeeker{
+			bytes.NewReader(chapters[0]),
+			bytes.NewReader(buf.Bytes()),
+			bytes.NewReader(chapters[1]),
+		}, collect(&got)))
+		require.Len(t, skipped, 1)
+		require.Equal(t, 1, skipped[0].Chapter)
+		require.Equal(t, -1, skipped[0].Chunk)
+		require.Equal(t, time.Duration(split)*1001*time.Millisecond, skipped[0].Start)
+		require.Equal(t, time.Duration(len(payloads))*1001*time.Millisecond, skipped[0].End)
+
+		require.Len(t, got, len(payloads))
+		require.Equal(t, skipped[0].End, got[split].Start)
+		require.Equal(t, skipped[0].End, GPSSamples(got[split].Elements)[0].Offset)
+	})
+}
@@ -0,0 +1,139 @@
+package gpmf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Device represents the telemetry of a single device, as recorded in
+// a DEVC element, for example the camera itself or an attached sensor.
+type Device struct {
+	// ID is the device ID from DVID, typically a uint32 or FourCC string.
+	ID any
+
+	// Name is the device name from DVNM.
+	Name string
+
+	// Streams are the streams of the device in the order first seen.
+	Streams []*Stream
+}
+
+// Stream returns the first stream of d whose data has the key, nil
+// if not found.
+func (d *Device) Stream(key string) *Stream {
+	for _, s := range d.Streams {
+		if s.Key == key {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// Stream represents a single stream of telemetry from a device, as
+// recorded in a STRM element.
+type Stream struct {
+	// Key is the FourCC of the streams data element, for example GPS5.
+	Key string
+
+	// Name is the stream name from STNM.
+	Name string
+
+	// StandardUnits are the SI units from SIUN, if any.
+	StandardUnits []string
+
+	// DisplayUnits are the display units from UNIT, if any.
+	DisplayUnits []string
+
+	// Data is the streams data, of the same type as the data element.
+	Data any
+
+	// Metadata is the streams metadata as applied by sticky items.
+	Metadata map[string]any
+}
+
+// Devices returns the devices recorded in elems.
+// Data from devices and streams which appear multiple times, for
+// example when elems contains multiple payloads, is combined.
+func Devices(elems []*Element) []*Device {
+	var devs []*Device
+	index := make(map[string]*Device)
+	for _, e := range elems {
+		if e.Header.FourCC() != KeyDevice {
+			continue
+		}
+
+		id, _ := e.Metadata[friendlyName(KeyDeviceID)]
+		name, _ := e.Metadata[friendlyName(KeyDeviceName)].(string)
+		k := fmt.Sprintf("%v/%s", id, name)
+		d, ok := index[k]
+		if !ok {
+			d = &Device{ID: id, Name: name}
+			index[k] = d
+			devs = append(devs, d)
+		}
+
+		for _, n := range e.Nested {
+			if n.Header.FourCC() == KeyStream {
+				d.add(n)
+			}
+		}
+	}
+
+	return devs
+}
+
+// add adds the stream strm to d.
+func (d *Device) add(strm *Element) {
+	if len(strm.Nested) == 0 {
+		return
+	}
+
+	// The data is always the last element of a stream.
+	data := strm.Nested[len(strm.Nested)-1]
+	key := data.Header.FourCC()
+	if s := d.Stream(key); s != nil {
+		s.Data = appendData(s.Data, data.Data)
+		for k, v := range strm.Metadata {
+			s.Metadata[k] = v
+		}
+		return
+	}
+
+	s := &Stream{
+		Key:           key,
+		Data:          data.Data,
+		StandardUnits: units(strm.Metadata[friendlyName(KeyStandardUnits)]),
+		DisplayUnits:  units(strm.Metadata[friendlyName(KeyDisplayUnits)]),
+		Metadata:      make(map[string]any, len(strm.Metadata)),
+	}
+	s.Name, _ = strm.Metadata[friendlyName(KeyStreamName)].(string)
+	for k, v := range strm.Metadata {
+		s.Metadata[k] = v
+	}
+
+	d.Streams = append(d.Streams, s)
+}
+
+// units returns v as a slice of units.
+func units(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	default:
+		return nil
+	}
+}
+
+// appendData returns b appended to a if both are slices of the same
+// type, otherwise b.
+func appendData(a, b any) any {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !av.IsValid() || !bv.IsValid() || av.Kind() != reflect.Slice || av.Type() != bv.Type() {
+		return b
+	}
+
+	return reflect.AppendSlice(av, bv).Interface()
+}
@@ -0,0 +1,84 @@
+package gpmf
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevices(t *testing.T) {
+	t.Run("fusion", func(t *testing.T) {
+		f, err := os.Open("../../../test/fusion.raw")
+		require.NoError(t, err)
+		defer f.Close() //nolint: errcheck
+
+		data, err := NewReader().Read(f)
+		require.NoError(t, err)
+
+		devs := Devices(data)
+		require.Len(t, devs, 1)
+		d := devs[0]
+		require.NotEmpty(t, d.Name)
+		keys := make([]string, len(d.Streams))
+		for i, s := range d.Streams {
+			keys[i] = s.Key
+		}
+		require.Equal(t, []string{"ACCL", "GYRO", "MAGN", "GPS5", "ISOG", "SHUT"}, keys)
+
+		gps := d.Stream(KeyGPS)
+		require.NotNil(t, gps)
+		require.NotEmpty(t, gps.Name)
+		require.Len(t, gps.DisplayUnits, 5)
+		_, ok := gps.Data.(GPSData)
+		require.True(t, ok)
+		require.Len(t, d.Stream(KeyAccel).StandardUnits, 1)
+		require.Nil(t, d.Stream("XXXX"))
+	})
+
+	t.Run("multi-device", func(t *testing.T) {
+		nested := func(key string, elems ...[]byte) []byte {
+			b := bytes.Join(elems, nil)
+			return klv(key, Nested, 1, uint16(len(b)), b) //nolint: gosec
+		}
+		dev := func(id uint32, name string, n int) []byte {
+			return nested(KeyDevice,
+				klv(KeyDeviceID, Uint32, 4, 1, be(t, id)),
+				klv(KeyDeviceName, String, 1, uint16(len(name)), []byte(name)), //nolint: gosec
+				nested(KeyStream,
+					klv(KeyStreamName, String, 1, 5, []byte("Accel")),
+					klv(KeyStandardUnits, String, 1, 5, []byte("m/s^2")),
+					klv(KeyAccel, Int16, 6, uint16(n), make([]byte, n*6)), //nolint: gosec
+				),
+			)
+		}
+
+		payload1 := bytes.Join([][]byte{dev(1, "Camera", 2), dev(2, "Karma", 3)}, nil)
+		payload2 := bytes.Join([][]byte{dev(1, "Camera", 4), dev(2, "Karma", 1)}, nil)
+		data, err := NewReader().Read(bytes.NewReader(append(payload1, payload2...)))
+		require.NoError(t, err)
+
+		devs := Devices(data)
+		require.Len(t, devs, 2)
+		for i, exp := range []struct {
+			id      uint32
+			name    string
+			samples int
+		}{
+			{id: 1, name: "Camera", samples: 6},
+			{id: 2, name: "Karma", samples: 4},
+		} {
+			d := devs[i]
+			require.Equal(t, exp.id, d.ID)
+			require.Equal(t, exp.name, d.Name)
+			require.Len(t, d.Streams, 1)
+			s := d.Streams[0]
+			require.Equal(t, KeyAccel, s.Key)
+			require.Equal(t, "Accel", s.Name)
+			require.Equal(t, []string{"m/s^2"}, s.StandardUnits)
+			require.Nil(t, s.DisplayUnits)
+			require.Len(t, s.Data, exp.samples)
+		}
+	})
+}
@@ -1,67 +1,566 @@
 package gpmf
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
 	"reflect"
-
-	"github.com/stevenh/tracktools/pkg/gopro/gpmf/geo"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
-// Dump dumps data as JSON.
-func Dump(data []*Element) error {
+// gapFactor is the multiple of the expected sample interval above
+// which the interval between two samples is reported as a gap.
+const gapFactor = 2
+
+// Dump writes data to w as indented JSON.
+func Dump(w io.Writer, data []*Element) error {
 	d := struct {
 		Data []*Element
 	}{Data: data}
 
-	enc := json.NewEncoder(os.Stdout)
+	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 	if err := enc.Encode(d); err != nil {
 		return fmt.Errorf("json encode: %w", err)
 	}
 
-	fmt.Println("")
-
 	return nil
 }
 
-// StatsDumper collates stats from slice of Elements using Walk.
+// Gap represents an interval between two consecutive samples of a
+// stream which exceeds the gap threshold.
+type Gap struct {
+	// Start is the offset of the last sample before the gap.
+	Start time.Duration
+
+	// End is the offset of the first sample after the gap.
+	End time.Duration
+}
+
+// Duration returns the duration of the gap.
+func (g Gap) Duration() time.Duration {
+	return g.End - g.Start
+}
+
+// Overlaps returns true if g overlaps the interval from start to end.
+func (g Gap) Overlaps(start, end time.Duration) bool {
+	return g.Start < end && g.End > start
+}
+
+// GPSQuality represents a period of consecutive GPS samples with the
+// same fix and dilution of precision rating.
+type GPSQuality struct {
+	// Start is the offset of the first sample.
+	Start time.Duration
+
+	// End is the offset of the last sample.
+	End time.Duration
+
+	// Fix is the GPS fix.
+	Fix GPSFix
+
+	// DoP is the worst dilution of precision of the samples.
+	DoP GPSDoP
+
+	// Samples is the number of samples.
+	Samples int
+}
+
+// StreamStats represents the statistics of a single stream.
+type StreamStats struct {
+	// Device is the name of the device which recorded the stream.
+	Device string
+
+	// Key is the FourCC of the streams data element.
+	Key string
+
+	// Name is the stream name.
+	Name string
+
+	// Units are the standard units of the stream, falling back
+	// to the display units if it has none.
+	Units []string
+
+	// Payloads is the number of payloads containing the stream.
+	Payloads int
+
+	// Samples is the number of samples.
+	Samples int
+
+	// Start is the offset of the first sample, if known.
+	Start time.Duration
+
+	// End is the offset of the last sample, if known.
+	End time.Duration
+
+	// Gaps are the intervals between samples which exceed the gap
+	// threshold, by default double the expected interval.
+	Gaps []Gap
+
+	// Dropped is the number of payloads whose total sample count
+	// (TSMP) shows that samples were lost before them.
+	Dropped int
+
+	// Duplicated is the number of payloads whose total sample count
+	// is unchanged from the previous payload, indicating repeated data.
+	Duplicated int
+
+	// Missing is the number of payloads, after the first containing
+	// the stream, which didn't contain it. Only payloads processed by
+	// StatsDumper.Payload are counted.
+	Missing int
+
+	// Quality is the GPS fix and dilution of precision over time,
+	// only set for GPS streams.
+	Quality []GPSQuality
+
+	// timed is true if the samples have offsets.
+	timed bool
+
+	// rates are the sample rates of each payload in Hz.
+	rates []float64
+
+	// total is the last total sample count, if hasTotal.
+	total    float64
+	hasTotal bool
+
+	// payload is the number of the last payload containing the stream.
+	payload int
+}
+
+// Rate returns the actual sample rate of the stream in Hz, as
+// determined by the sample offsets, 0 if unknown.
+func (s *StreamStats) Rate() float64 {
+	if !s.timed || s.Samples < 2 || s.End <= s.Start {
+		return 0
+	}
+
+	return float64(s.Samples-1) / (s.End - s.Start).Seconds()
+}
+
+// NominalRate returns the nominal sample rate of the stream in Hz,
+// the median of the number of samples per second of each payload,
+// 0 if unknown. Only payloads processed by StatsDumper.Payload are
+// included.
+func (s *StreamStats) NominalRate() float64 {
+	if len(s.rates) == 0 {
+		return 0
+	}
+
+	rates := slices.Clone(s.rates)
+	slices.Sort(rates)
+	mid := len(rates) / 2
+	if len(rates)%2 == 0 {
+		return (rates[mid-1] + rates[mid]) / 2
+	}
+
+	return rates[mid]
+}
+
+// StatsOption is an option for a StatsDumper.
+type StatsOption func(*StatsDumper)
+
+// GapThreshold sets the interval between consecutive samples above
+// which it's reported as a gap.
+// Default: 0, double the expected interval of each stream.
+func GapThreshold(d time.Duration) StatsOption {
+	return func(s *StatsDumper) {
+		s.gapThreshold = d
+	}
+}
+
+// StatsDumper collates per stream stats from Elements using Walk,
+// or from Payloads using Payload which adds payload level diagnostics.
 type StatsDumper struct {
-	lat, lon float64
-	counts   map[string]int
-	p        *geo.Processor
+	streams      []*StreamStats
+	index        map[string]*StreamStats
+	gapThreshold time.Duration
+
+	// payloads is the number of payloads processed by Payload.
+	payloads int
+
+	// current is the payload being processed by Payload.
+	current *Payload
 }
 
 // NewStatsDumper returns a fully initialised StatsDumper.
-func NewStatsDumper() *StatsDumper {
-	return &StatsDumper{
-		counts: make(map[string]int),
-		p:      geo.NewProcessor(),
+func NewStatsDumper(options ...StatsOption) *StatsDumper {
+	s := &StatsDumper{
+		index: make(map[string]*StreamStats),
 	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// Payload is a PayloadFunc that collects stats including the nominal
+// sample rate and the payloads missing each stream.
+func (s *StatsDumper) Payload(p *Payload) error {
+	s.payloads++
+	s.current = p
+	defer func() {
+		s.current = nil
+	}()
+
+	if err := Walk(p.Elements, s.Walk); err != nil {
+		return err
+	}
+
+	for _, st := range s.streams {
+		if st.payload != s.payloads {
+			st.Missing++
+		}
+	}
+
+	return nil
 }
 
 // Walk is WalkFunc that collects stats.
-func (s *StatsDumper) Walk(e *Element) {
-	if d, ok := e.Data.(GPSData); ok {
-		fmt.Println("fix:", e.Metadata["gps_fix_description"])
-		fmt.Println("dop:", e.Metadata["gps_dilution_of_precision"])
-		for _, v := range d {
-			di := s.p.Distance(s.lat, s.lon, v.Latitude, v.Longitude)
-			fmt.Printf("distance: %.2f %s\n", di, v)
+func (s *StatsDumper) Walk(e *Element) error {
+	data := streamData(e)
+	if data == nil {
+		return nil
+	}
+
+	id, _ := e.lookup(friendlyName(KeyDeviceID))
+	dev, _ := e.lookup(friendlyName(KeyDeviceName))
+	name, _ := dev.(string)
+	key := fmt.Sprintf("%v/%s/%s", id, name, data.Header.FourCC())
+	st, ok := s.index[key]
+	if !ok {
+		st = &StreamStats{
+			Device: name,
+			Key:    data.Header.FourCC(),
+			Units:  units(e.Metadata[friendlyName(KeyStandardUnits)]),
+		}
+		st.Name, _ = e.Metadata[friendlyName(KeyStreamName)].(string)
+		if st.Units == nil {
+			st.Units = units(e.Metadata[friendlyName(KeyDisplayUnits)])
+		}
+		s.index[key] = st
+		s.streams = append(s.streams, st)
+	}
+
+	st.Payloads++
+	st.payload = s.payloads
+	offs := sampleOffsets(data.Data)
+	n := dataLen(data.Data)
+	s.payloadStats(st, data, n)
+	if offs == nil {
+		st.Samples += n
+		return nil
+	}
+
+	threshold := s.gapThreshold
+	if rate, ok := data.lookupFloat(metaSampleRate); ok && rate > 0 && threshold <= 0 {
+		threshold = seconds(1 / rate * gapFactor)
+	}
+
+	for _, v := range offs {
+		switch {
+		case !st.timed:
+			st.Start, st.timed = v, true
+		case threshold > 0 && v-st.End > threshold:
+			st.Gaps = append(st.Gaps, Gap{Start: st.End, End: v})
+		}
+		st.End = v
+		st.Samples++
+	}
+
+	if gps, ok := data.Data.(GPSData); ok {
+		st.quality(gps)
+	}
+
+	return nil
+}
+
+// payloadStats updates the payload level stats of st from data
+// which contains n samples.
+func (s *StatsDumper) payloadStats(st *StreamStats, data *Element, n int) {
+	if p := s.current; p != nil && p.End > p.Start && n > 0 {
+		st.rates = append(st.rates, float64(n)/(p.End-p.Start).Seconds())
+	}
+
+	total, ok := data.lookupFloat(friendlyName(KeyTotalSamples))
+	if !ok {
+		return
+	}
+
+	if st.hasTotal {
+		switch {
+		case total == st.total:
+			st.Duplicated++
+		case total-float64(n) > st.total:
+			st.Dropped++
+		}
+	}
+	st.total, st.hasTotal = total, true
+}
+
+// quality adds the fix and dilution of precision of gps to st.
+func (st *StreamStats) quality(gps GPSData) {
+	for _, v := range gps {
+		if n := len(st.Quality); n > 0 {
+			q := &st.Quality[n-1]
+			if q.Fix == v.Fix && q.DoP.Rating() == v.DoP.Rating() {
+				q.End = v.Offset
+				q.DoP = max(q.DoP, v.DoP)
+				q.Samples++
+				continue
+			}
+		}
+
+		st.Quality = append(st.Quality, GPSQuality{
+			Start:   v.Offset,
+			End:     v.Offset,
+			Fix:     v.Fix,
+			DoP:     v.DoP,
+			Samples: 1,
+		})
+	}
+}
+
+// Stats returns the collated stats for each stream in the order
+// they were first seen.
+func (s *StatsDumper) Stats() []*StreamStats {
+	return s.streams
+}
+
+// Stream returns the stats of the first stream with key, nil if
+// not found.
+func (s *StatsDumper) Stream(key string) *StreamStats {
+	for _, v := range s.streams {
+		if v.Key == key {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// GPS returns the stats of the GPS stream, preferring GPS9 over GPS5
+// as GPSSamples does, nil if there is none.
+func (s *StatsDumper) GPS() *StreamStats {
+	if st := s.Stream(KeyGPS9); st != nil {
+		return st
+	}
+
+	return s.Stream(KeyGPS)
+}
+
+// Results writes the stats results to w as tables, the first with
+// a row per stream followed by the gaps and GPS quality, if any.
+func (s *StatsDumper) Results(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEVICE\tKEY\tNAME\tUNITS\tPAYLOADS\tSAMPLES\tNOMINAL HZ\tHZ\tSTART\tEND\tGAPS\tDROPPED\tDUPLICATED\tMISSING")
+	var gaps bool
+	for _, v := range s.streams {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%.3f\t%.3f\t%s\t%s\t%d\t%d\t%d\t%d\n",
+			v.Device,
+			v.Key,
+			v.Name,
+			strings.Join(v.Units, ","),
+			v.Payloads,
+			v.Samples,
+			v.NominalRate(),
+			v.Rate(),
+			v.Start,
+			v.End,
+			len(v.Gaps),
+			v.Dropped,
+			v.Duplicated,
+			v.Missing,
+		)
+		gaps = gaps || len(v.Gaps) > 0
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("stats results: %w", err)
+	}
+
+	if gaps {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "DEVICE\tKEY\tGAP START\tGAP END\tDURATION")
+		for _, v := range s.streams {
+			for _, g := range v.Gaps {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", v.Device, v.Key, g.Start, g.End, g.Duration())
+			}
 		}
+
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("stats gaps: %w", err)
+		}
+	}
+
+	if gps := s.GPS(); gps != nil && len(gps.Quality) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "GPS START\tEND\tFIX\tDOP\tRATING\tSAMPLES")
+		for _, q := range gps.Quality {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%s\t%d\n", q.Start, q.End, q.Fix, q.DoP, q.DoP.Rating(), q.Samples)
+		}
+
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("stats gps quality: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sampleOffsets returns the offsets of the samples in data, nil
+// if data isn't a slice of samples with an Offset.
+func sampleOffsets(data any) []time.Duration {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	f, ok := v.Type().Elem().FieldByName("Offset")
+	if !ok || f.Type != reflect.TypeOf(time.Duration(0)) {
+		return nil
+	}
+
+	offs := make([]time.Duration, v.Len())
+	for i := range offs {
+		offs[i] = time.Duration(v.Index(i).FieldByIndex(f.Index).Int())
+	}
+
+	return offs
+}
+
+// CSVDumper writes the samples of a single stream as CSV using Walk,
+// with one row per sample and one column per value. A header row is
+// written before the first sample and again each time the columns
+// change, as they can for variable width streams such as HUES.
+type CSVDumper struct {
+	key    string
+	w      *csv.Writer
+	header []string
+}
+
+// NewCSVDumper returns a new CSVDumper which writes the samples of
+// elements with key to w.
+func NewCSVDumper(w io.Writer, key string) *CSVDumper {
+	return &CSVDumper{
+		key: key,
+		w:   csv.NewWriter(w),
+	}
+}
+
+// Walk is WalkFunc that writes samples.
+func (c *CSVDumper) Walk(e *Element) error {
+	if e.Header.FourCC() != c.key || e.Header.Nested() {
+		return nil
 	}
 
 	v := reflect.ValueOf(e.Data)
-	if v.Type().Kind() == reflect.Slice {
-		s.counts[e.Header.FourCC()] += v.Len()
+	if v.Kind() != reflect.Slice {
+		return c.write(v)
 	}
+
+	for i := range v.Len() {
+		if err := c.write(v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// Results prints the stats results.
-func (s *StatsDumper) Results(w io.Writer) {
-	for k, v := range s.counts {
-		fmt.Fprintln(w, k, "=", v)
+// write writes a single sample v.
+func (c *CSVDumper) write(v reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+
+	names, vals := []string{"offset"}, []string{""}
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Offset"); f.IsValid() && f.Type() == reflect.TypeOf(time.Duration(0)) {
+			vals[0] = strconv.FormatFloat(time.Duration(f.Int()).Seconds(), 'f', -1, 64)
+		}
+	}
+
+	names, vals = csvValues("", v, names, vals)
+	if !slices.Equal(names, c.header) {
+		if err := c.w.Write(names); err != nil {
+			return fmt.Errorf("csv header: %w", err)
+		}
+		c.header = names
+	}
+
+	if err := c.w.Write(vals); err != nil {
+		return fmt.Errorf("csv write: %w", err)
+	}
+
+	return nil
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (c *CSVDumper) Flush() error {
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return fmt.Errorf("csv flush: %w", err)
+	}
+
+	return nil
+}
+
+// csvValues appends the column names and values of v to names and vals
+// flattening structs and slices, and excluding Offset fields.
+func csvValues(name string, v reflect.Value, names, vals []string) ([]string, []string) {
+	column := name
+	if column == "" {
+		column = "value"
+	}
+
+	switch val := v.Interface().(type) {
+	case time.Time:
+		return append(names, column), append(vals, val.UTC().Format(time.RFC3339Nano))
+	case fmt.Stringer:
+		if v.Kind() != reflect.Struct {
+			return append(names, column), append(vals, val.String())
+		}
+	}
+
+	switch v.Kind() { //nolint: exhaustive
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return append(names, column), append(vals, "")
+		}
+		return csvValues(name, v.Elem(), names, vals)
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() || f.Name == "Offset" {
+				continue
+			}
+
+			n := f.Name
+			if name != "" {
+				n = name + "." + f.Name
+			}
+			names, vals = csvValues(n, v.Field(i), names, vals)
+		}
+		return names, vals
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			names, vals = csvValues(fmt.Sprintf("%s[%d]", column, i), v.Index(i), names, vals)
+		}
+		return names, vals
+	case reflect.Float32, reflect.Float64:
+		return append(names, column), append(vals, strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()))
+	default:
+		return append(names, column), append(vals, fmt.Sprint(v.Interface()))
 	}
 }
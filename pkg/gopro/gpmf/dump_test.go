@@ -0,0 +1,194 @@
+package gpmf
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testRaw decodes the raw test file name calling fn for each payload.
+func testRaw(t *testing.T, name string, fn PayloadFunc) {
+	t.Helper()
+
+	f, err := os.Open("../../../test/" + name + ".raw") //nolint: gosec
+	require.NoError(t, err)
+	defer f.Close() //nolint: errcheck
+
+	require.NoError(t, NewDecoder().DecodeRaw(f, time.Second, fn))
+}
+
+func TestDecodeRaw(t *testing.T) {
+	var got []*Payload
+	testRaw(t, "hero6-multi-chunk", func(p *Payload) error {
+		got = append(got, p)
+		return nil
+	})
+
+	require.Len(t, got, len(testPayloads(t, "hero6-multi-chunk")))
+	for i, p := range got {
+		require.Equal(t, time.Duration(i)*time.Second, p.Start)
+		require.Equal(t, time.Duration(i+1)*time.Second, p.End)
+		gps := GPSSamples(p.Elements)
+		require.NotEmpty(t, gps)
+		require.Equal(t, p.Start, gps[0].Offset)
+	}
+}
+
+func TestDump(t *testing.T) {
+	var buf bytes.Buffer
+	testRaw(t, "hero6", func(p *Payload) error {
+		return Dump(&buf, p.Elements)
+	})
+
+	var v struct {
+		Data []map[string]any
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &v))
+	require.NotEmpty(t, v.Data)
+}
+
+func TestStatsDumper(t *testing.T) {
+	s := NewStatsDumper()
+	testRaw(t, "hero6-multi-chunk", s.Payload)
+
+	stats := s.Stats()
+	require.NotEmpty(t, stats)
+
+	gps := s.GPS()
+	require.NotNil(t, gps)
+	require.Equal(t, KeyGPS, gps.Key)
+	require.Same(t, gps, s.Stream(KeyGPS))
+	require.Nil(t, s.Stream("XXXX"))
+	require.Equal(t, "Camera", gps.Device)
+	require.Equal(t, []string{"deg", "deg", "m", "m/s", "m/s"}, gps.Units)
+	require.Equal(t, 21, gps.Payloads)
+	require.Equal(t, 381, gps.Samples)
+	require.Equal(t, time.Duration(0), gps.Start)
+	require.InDelta(t, 18, gps.Rate(), 0.5)
+	require.InDelta(t, 18, gps.NominalRate(), 0.5)
+	require.Zero(t, gps.Dropped)
+	require.Zero(t, gps.Duplicated)
+	require.Zero(t, gps.Missing)
+	require.Len(t, gps.Gaps, 1)
+	require.Greater(t, gps.Gaps[0].Duration(), 2*time.Second/18)
+
+	require.Len(t, gps.Quality, 3)
+	require.Equal(t, GPS3DLock, gps.Quality[0].Fix)
+	require.Equal(t, "fair", gps.Quality[0].DoP.Rating())
+	require.Equal(t, "moderate", gps.Quality[1].DoP.Rating())
+	require.Equal(t, "good", gps.Quality[2].DoP.Rating())
+	require.Equal(t, gps.Samples, gps.Quality[0].Samples+gps.Quality[1].Samples+gps.Quality[2].Samples)
+	require.Equal(t, gps.End, gps.Quality[2].End)
+
+	var buf bytes.Buffer
+	require.NoError(t, s.Results(&buf))
+	tables := strings.Split(strings.TrimSpace(buf.String()), "\n\n")
+	require.Len(t, tables, 3)
+	lines := strings.Split(tables[0], "\n")
+	require.Len(t, lines, len(stats)+1)
+	require.True(t, strings.HasPrefix(lines[0], "DEVICE"))
+	require.True(t, strings.HasPrefix(tables[2], "GPS START"))
+
+	t.Run("threshold", func(t *testing.T) {
+		s := NewStatsDumper(GapThreshold(time.Second))
+		testRaw(t, "hero6-multi-chunk", s.Payload)
+		for _, v := range s.Stats() {
+			require.Empty(t, v.Gaps, v.Key)
+		}
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		s := NewStatsDumper()
+		testRaw(t, "hero6-multi-chunk", func(p *Payload) error {
+			return Walk(p.Elements, s.Walk)
+		})
+		require.Equal(t, 381, s.GPS().Samples)
+		require.Zero(t, s.GPS().NominalRate())
+	})
+
+	t.Run("payloads", func(t *testing.T) {
+		// setTotal sets the total samples of the GPS stream in p.
+		setTotal := func(p *Payload, v float64) {
+			require.NoError(t, Walk(p.Elements, func(e *Element) error {
+				if d := streamData(e); d != nil && d.Header.FourCC() == KeyGPS {
+					e.Metadata[friendlyName(KeyTotalSamples)] = v
+				}
+				return nil
+			}))
+		}
+
+		s := NewStatsDumper()
+		var idx int
+		testRaw(t, "hero6-multi-chunk", func(p *Payload) error {
+			idx++
+			switch idx {
+			case 1, 2:
+				// Second payload repeats the total of the first.
+				setTotal(p, 18)
+			case 3:
+				// No GPS stream.
+				return s.Payload(&Payload{Start: p.Start, End: p.End})
+			case 4:
+				// Samples lost.
+				setTotal(p, 1000)
+			}
+			return s.Payload(p)
+		})
+
+		gps := s.GPS()
+		require.Equal(t, 1, gps.Duplicated)
+		require.Equal(t, 1, gps.Missing)
+		require.Equal(t, 1, gps.Dropped)
+	})
+}
+
+func TestCSVDumper(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewCSVDumper(&buf, KeyGPS)
+	var samples int
+	testRaw(t, "hero6", func(p *Payload) error {
+		samples += len(GPSSamples(p.Elements))
+		return Walk(p.Elements, c.Walk)
+	})
+	require.NoError(t, c.Flush())
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, samples+1)
+	require.Equal(t, []string{"offset", "Latitude", "Longitude", "Altitude", "Speed", "Speed3D", "Time", "DoP", "Fix"}, rows[0])
+	require.Equal(t, "0", rows[1][0])
+	require.Equal(t, "33.1265542", rows[1][1])
+
+	t.Run("variable-width", func(t *testing.T) {
+		var buf bytes.Buffer
+		c := NewCSVDumper(&buf, KeyFrameHues)
+		e := &Element{
+			Header: Header{Key: [4]byte([]byte(KeyFrameHues)), Type: Complex},
+			Data: HueData{
+				{Hues: []Hue{{Hue: 10, Weight: 0.5}}},
+				{Hues: []Hue{{Hue: 20, Weight: 0.25}, {Hue: 30, Weight: 0.75}}},
+				{Hues: []Hue{{Hue: 40, Weight: 0.5}, {Hue: 50, Weight: 0.5}}},
+			},
+		}
+		require.NoError(t, c.Walk(e))
+		require.NoError(t, c.Flush())
+
+		r := csv.NewReader(&buf)
+		r.FieldsPerRecord = -1
+		rows, err := r.ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 5)
+		require.Len(t, rows[0], 3)
+		require.Len(t, rows[1], 3)
+		require.Len(t, rows[2], 5)
+		require.Equal(t, []string{"offset", "Hues[0].Hue", "Hues[0].Weight", "Hues[1].Hue", "Hues[1].Weight"}, rows[2])
+		require.Len(t, rows[3], 5)
+		require.Len(t, rows[4], 5)
+	})
+}
@@ -161,10 +161,48 @@ func (e Element) String() string {
 
 // MetadataByKey returns the elements metadata for key.
 func (e *Element) MetadataByKey(key string) (any, bool) {
-	v, ok := e.Metadata[keyNames[key]]
+	v, ok := e.Metadata[friendlyName(key)]
 	return v, ok
 }
 
+// SetData sets Data to v and discards the data e was read from, so
+// a Writer encodes it from v according to Header.Type, for example to
+// modify or anonymise values before writing them back. v must be a
+// type the Writer supports such as a string or slice of numbers, not
+// a parsed type such as GPSData.
+func (e *Element) SetData(v any) {
+	e.Data = v
+	e.raw = nil
+}
+
+// lookup returns the metadata value for the friendly name from e
+// or its closest ancestor which has it.
+func (e *Element) lookup(name string) (any, bool) {
+	for v := e; v != nil; v = v.parent {
+		if val, ok := v.Metadata[name]; ok {
+			return val, true
+		}
+	}
+
+	return nil, false
+}
+
+// lookupFloat returns the metadata value for the friendly name as
+// returned by lookup converted to a float64, if it's a single number.
+func (e *Element) lookupFloat(name string) (float64, bool) {
+	v, ok := e.lookup(name)
+	if !ok {
+		return 0, false
+	}
+
+	vals, err := floatSlice(v)
+	if err != nil || len(vals) != 1 {
+		return 0, false
+	}
+
+	return vals[0], true
+}
+
 // initMetadata sets the metadata on e from its parents.
 func (e *Element) initMetadata() {
 	e.Metadata = e.parent.Metadata
@@ -180,6 +218,12 @@ func (e *Element) initMetadata() {
 // format returns the element data formatted according
 // to its Header information.
 func (e *Element) format(parent *Element) error {
+	if e.Header.Type == Compressed {
+		if err := e.decompress(); err != nil {
+			return err
+		}
+	}
+
 	if err := e.formatBasic(); err != nil {
 		return err
 	}
@@ -195,7 +239,7 @@ func (e *Element) format(parent *Element) error {
 		parent.scale = nil
 	}
 
-	if f := keyParsers[e.Header.FourCC()]; f != nil {
+	if f := keyParser(e.Header.FourCC()); f != nil {
 		if err := f(e); err != nil {
 			return err
 		}
@@ -237,10 +281,10 @@ func (e *Element) formatBasic() error { //nolint: cyclop
 	case Date:
 		return e.formatDates()
 	case Complex:
-		// TODO(steve): support fully.
-		return nil
+		return e.formatComplex()
 	case Compressed:
-		return fmt.Errorf("element: type %s not supported", e.Header.Type)
+		// Decompression replaces the type so this is unexpected.
+		return fmt.Errorf("element: type %s not decompressed", e.Header.Type)
 	case Nested:
 		// Nested doesn't have raw data.
 		return nil
@@ -520,6 +564,7 @@ func (e *Element) formatDates() error {
 	return nil
 }
 
-func (e *Element) friendlyName() string {
+// FriendlyName returns the friendly name of the elements key.
+func (e *Element) FriendlyName() string {
 	return friendlyName(e.Header.FourCC())
 }
@@ -1,7 +1,7 @@
 package gpmf
 
 import (
-	"math"
+	"fmt"
 )
 
 // Face type sizes.
@@ -112,65 +112,85 @@ func parseFace(e *Element) error {
 		return err
 	}
 
+	d, ok := e.Data.(StructData)
+	if !ok {
+		return fmt.Errorf("%s: unexpected data type %T (expected StructData)", e.FriendlyName(), e.Data)
+	}
+
 	switch def {
 	case faceDefHero6:
-		parseFaces(e, parseFace6)
+		parseFaces(e, d, parseFace6)
 	case faceDefHero7:
-		parseFaces(e, parseFace7)
+		parseFaces(e, d, parseFace7)
 	case faceDefHero8:
-		parseFaces(e, parseFace8)
+		parseFaces(e, d, parseFace8)
 	case faceDefHero10:
-		parseFaces(e, parseFace10)
+		parseFaces(e, d, parseFace10)
 	}
 
 	return nil
 }
 
-// parseFaces parses a set of faces using fn.
-func parseFaces[T face](e *Element, fn func(*T, []byte)) {
-	count := int(e.Header.Count)
-	size := int(e.Header.Size)
-	d := make([]T, count)
-	for i, j := 0, 0; i < count; i, j = i+1, j+size {
-		var f T
-		fn(&f, e.raw[j:])
-		d[i] = f
+// parseFaces parses a set of faces from d using fn.
+func parseFaces[T face](e *Element, d StructData, fn func(*T, []any)) {
+	faces := make([]T, len(d))
+	for i, s := range d {
+		fn(&faces[i], s.Fields)
 	}
 
-	e.Data = d
+	e.Data = faces
+}
+
+// faceField represents the types of the fields of a face.
+type faceField interface {
+	uint8 | uint16 | uint32 | float32
+}
+
+// field returns the value of fields[i] as T. Scaled fields are
+// float64 so are converted to T, other types return the zero value
+// of T which can't happen for a validated type definition.
+func field[T faceField](fields []any, i int) T {
+	switch v := fields[i].(type) {
+	case T:
+		return v
+	case float64:
+		return T(v)
+	default:
+		return 0
+	}
 }
 
 // parseFace6 parses a Hero 6 face.
-func parseFace6(f *Face6, raw []byte) {
-	f.ID = byteOrder.Uint32(raw)
-	f.X = math.Float32frombits(byteOrder.Uint32(raw[4:]))
-	f.Y = math.Float32frombits(byteOrder.Uint32(raw[8:]))
-	f.Width = math.Float32frombits(byteOrder.Uint32(raw[12:]))
-	f.Height = math.Float32frombits(byteOrder.Uint32(raw[16:]))
+func parseFace6(f *Face6, fields []any) {
+	f.ID = field[uint32](fields, 0)
+	f.X = field[float32](fields, 1)
+	f.Y = field[float32](fields, 2)
+	f.Width = field[float32](fields, 3)
+	f.Height = field[float32](fields, 4)
 }
 
 // parseFace7 parses a Hero 7 face.
-func parseFace7(f *Face7, raw []byte) {
-	parseFace6(&f.Face6, raw)
-	f.Smile = math.Float32frombits(byteOrder.Uint32(raw[92:]))
+func parseFace7(f *Face7, fields []any) {
+	parseFace6(&f.Face6, fields)
+	f.Smile = field[float32](fields, 22)
 }
 
 // parseFace8 parses a Hero 8+ face.
-func parseFace8(f *Face8, raw []byte) {
-	parseFace6(&f.Face6, raw)
-	f.Confidence = math.Float32frombits(byteOrder.Uint32(raw[20:]))
-	f.Smile = math.Float32frombits(byteOrder.Uint32(raw[24:]))
+func parseFace8(f *Face8, fields []any) {
+	parseFace6(&f.Face6, fields)
+	f.Confidence = field[float32](fields, 5)
+	f.Smile = field[float32](fields, 6)
 }
 
 // parseFace10 parses a Hero 10+ face.
-func parseFace10(f *Face10, raw []byte) {
-	f.Version = raw[0]
-	f.Confidence = raw[1]
-	f.ID = byteOrder.Uint16(raw[3:])
-	f.X = byteOrder.Uint16(raw[5:])
-	f.Y = byteOrder.Uint16(raw[7:])
-	f.Width = byteOrder.Uint16(raw[9:])
-	f.Height = byteOrder.Uint16(raw[11:])
-	f.Smile = raw[13]
-	f.Blink = raw[14]
+func parseFace10(f *Face10, fields []any) {
+	f.Version = field[uint8](fields, 0)
+	f.Confidence = field[uint8](fields, 1)
+	f.ID = field[uint16](fields, 2)
+	f.X = field[uint16](fields, 3)
+	f.Y = field[uint16](fields, 4)
+	f.Width = field[uint16](fields, 5)
+	f.Height = field[uint16](fields, 6)
+	f.Smile = field[uint8](fields, 7)
+	f.Blink = field[uint8](fields, 8)
 }
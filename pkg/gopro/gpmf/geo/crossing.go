@@ -0,0 +1,225 @@
+package geo
+
+import (
+	"math"
+	"time"
+
+	"github.com/tidwall/geodesic"
+)
+
+const (
+	// onLineDistance is the distance in metres from a line within
+	// which a point is considered to be on it.
+	onLineDistance = 0.001
+
+	// DefaultHeadingTolerance is the default tolerance in degrees
+	// between the direction of travel and the bearing of a line
+	// for a crossing to count.
+	DefaultHeadingTolerance = 60.0
+)
+
+// Fix represents a position at a time offset.
+type Fix struct {
+	// Latitude in degrees.
+	Latitude float64
+
+	// Longitude in degrees.
+	Longitude float64
+
+	// Speed is the speed in m/s, if known.
+	Speed float64
+
+	// Offset is the time offset of the fix.
+	Offset time.Duration
+}
+
+// Crossing represents the crossing of a line between two fixes.
+type Crossing struct {
+	// Latitude of the crossing point in degrees.
+	Latitude float64
+
+	// Longitude of the crossing point in degrees.
+	Longitude float64
+
+	// Offset is the time offset of the crossing interpolated
+	// between From and To.
+	Offset time.Duration
+
+	// From is the fix before the crossing.
+	From Fix
+
+	// To is the fix after, or on, the line.
+	To Fix
+}
+
+// Crossing returns the point at which the segment from (lat1, lon1)
+// to (lat2, lon2) crosses the line from (latA, lonA) to (latB, lonB)
+// and the fraction of the segment's length before it, false if it
+// doesn't cross. A segment which starts on the line doesn't cross it,
+// so a point exactly on the line is only crossed by the segment which
+// ends at it.
+// Latitudes and longitudes are in degrees.
+func (p *Processor) Crossing(
+	lat1, lon1,
+	lat2, lon2,
+	latA, lonA,
+	latB, lonB float64,
+) (lat, lon, frac float64, ok bool) {
+	// Check which side of the line each end of the segment is
+	// first, as it's much cheaper than the intersection.
+	side1 := p.side(lat1, lon1, latA, lonA, latB, lonB)
+	side2 := p.side(lat2, lon2, latA, lonA, latB, lonB)
+	switch {
+	case side1 == 0, side1*side2 > 0:
+		return 0, 0, 0, false
+	case side2 == 0:
+		// On the line through A and B, so check it's between them.
+		length := p.Distance(latA, lonA, latB, lonB) + onLineDistance
+		if p.Distance(latA, lonA, lat2, lon2) > length || p.Distance(latB, lonB, lat2, lon2) > length {
+			return 0, 0, 0, false
+		}
+		return lat2, lon2, 1, true
+	}
+
+	lat, lon, err := p.gnomonic.Intersect(
+		lat1, lon1,
+		lat2, lon2,
+		latA, lonA,
+		latB, lonB,
+	)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	total := p.Distance(lat1, lon1, lat2, lon2)
+	if total == 0 {
+		return lat, lon, 1, true
+	}
+
+	return lat, lon, min(p.Distance(lat1, lon1, lat, lon)/total, 1), true
+}
+
+// side returns the signed distance in metres of (lat0, lon0) from the
+// line through (lat1, lon1) and (lat2, lon2), zero if it's within
+// onLineDistance to allow for rounding errors.
+// Latitudes and longitudes are in degrees.
+func (p *Processor) side(lat0, lon0, lat1, lon1, lat2, lon2 float64) float64 {
+	d := sinDeltaBearing(
+		lat1*radians, lon1*radians,
+		lat2*radians, lon2*radians,
+		lat0*radians, lon0*radians,
+	) * p.Distance(lat1, lon1, lat0, lon0)
+	if math.Abs(d) < onLineDistance {
+		return 0
+	}
+
+	return d
+}
+
+// DetectorOption is an option for a LineDetector.
+type DetectorOption func(*LineDetector)
+
+// Heading sets the direction of travel across the line as a bearing
+// in degrees, crossings where the direction of travel between the
+// fixes differs by more than tolerance degrees are ignored. This
+// prevents crossing the line backwards, or travelling almost parallel
+// to it, being detected.
+// Default: crossings in either direction count.
+func Heading(bearing, tolerance float64) DetectorOption {
+	return func(d *LineDetector) {
+		d.bearing = bearing
+		d.tolerance = tolerance
+		d.directional = true
+	}
+}
+
+// LineDetector detects the crossings of a line by consecutive fixes,
+// interpolating the time of each crossing.
+type LineDetector struct {
+	p *Processor
+
+	// latA, lonA, latB, lonB are the end points of the line.
+	latA, lonA float64
+	latB, lonB float64
+
+	// bearing and tolerance are the direction of travel and its
+	// tolerance in degrees, if directional.
+	bearing     float64
+	tolerance   float64
+	directional bool
+
+	// prev is the previous fix, if any.
+	prev    Fix
+	hasPrev bool
+}
+
+// NewLineDetector returns a new LineDetector for the line from
+// (lat1, lon1) to (lat2, lon2) in degrees which uses p for its
+// calculations. The line is extended at each end by the tolerance
+// of p to allow for GPS inaccuracy.
+func NewLineDetector(p *Processor, lat1, lon1, lat2, lon2 float64, options ...DetectorOption) *LineDetector {
+	d := &LineDetector{p: p}
+	for _, o := range options {
+		o(d)
+	}
+
+	var azi1, azi2 float64
+	geodesic.WGS84.Inverse(lat1, lon1, lat2, lon2, nil, &azi1, &azi2)
+	geodesic.WGS84.Direct(lat1, lon1, azi1+halfDegrees, p.tolerance, &d.latA, &d.lonA, nil)
+	geodesic.WGS84.Direct(lat2, lon2, azi2, p.tolerance, &d.latB, &d.lonB, nil)
+
+	return d
+}
+
+// Add adds the next fix f returning the crossing and true if the
+// segment from the previous fix to f crosses the line, false otherwise.
+// Fixes must be added in order, each physical crossing results in
+// a single crossing.
+func (d *LineDetector) Add(f Fix) (Crossing, bool) {
+	prev, hasPrev := d.prev, d.hasPrev
+	d.prev, d.hasPrev = f, true
+	if !hasPrev {
+		return Crossing{}, false
+	}
+
+	lat, lon, frac, ok := d.p.Crossing(
+		prev.Latitude, prev.Longitude,
+		f.Latitude, f.Longitude,
+		d.latA, d.lonA,
+		d.latB, d.lonB,
+	)
+	if !ok || !d.forward(prev, f) {
+		return Crossing{}, false
+	}
+
+	// Offsets of consecutive fixes can overlap, so never go backwards.
+	dur := max(f.Offset-prev.Offset, 0)
+
+	return Crossing{
+		Latitude:  lat,
+		Longitude: lon,
+		Offset:    prev.Offset + time.Duration(frac*float64(dur)),
+		From:      prev,
+		To:        f,
+	}, true
+}
+
+// forward returns true if the direction of travel from f1 to f2 is
+// within the tolerance of the bearing, or the detector isn't
+// directional.
+func (d *LineDetector) forward(f1, f2 Fix) bool {
+	if !d.directional {
+		return true
+	}
+
+	var azi float64
+	geodesic.WGS84.Inverse(f1.Latitude, f1.Longitude, f2.Latitude, f2.Longitude, nil, &azi, nil)
+
+	return math.Abs(math.Remainder(azi-d.bearing, 2*halfDegrees)) <= d.tolerance
+}
+
+// Reset clears the previous fix, so the next fix added starts a new
+// sequence, for example after a gap in the data.
+func (d *LineDetector) Reset() {
+	d.hasPrev = false
+}
@@ -0,0 +1,154 @@
+package geo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/geodesic"
+)
+
+// testFixes returns the fixes at 18Hz of a vehicle travelling at speed
+// in m/s on bearing from dist metres before (lat, lon) to dist after.
+func testFixes(lat, lon, bearing, speed, dist float64) []Fix {
+	var lat1, lon1 float64
+	geodesic.WGS84.Direct(lat, lon, bearing+halfDegrees, dist, &lat1, &lon1, nil)
+
+	var fixes []Fix
+	interval := time.Second / 18
+	for i := 0; ; i++ {
+		d := speed * (time.Duration(i) * interval).Seconds()
+		if d > 2*dist {
+			return fixes
+		}
+
+		f := Fix{Offset: time.Duration(i) * interval}
+		geodesic.WGS84.Direct(lat1, lon1, bearing, d, &f.Latitude, &f.Longitude, nil)
+		fixes = append(fixes, f)
+	}
+}
+
+func TestLineDetector(t *testing.T) {
+	// Start line with a width of 20m.
+	const lat, lon, bearing = 50.857933, -0.752594, 173.0
+	var lat1, lon1, lat2, lon2 float64
+	geodesic.WGS84.Direct(lat, lon, bearing+90, 10, &lat1, &lon1, nil)
+	geodesic.WGS84.Direct(lat, lon, bearing-90, 10, &lat2, &lon2, nil)
+
+	// Point 5m along the line from its centre.
+	var latOff, lonOff float64
+	geodesic.WGS84.Direct(lat, lon, bearing+90, 5, &latOff, &lonOff, nil)
+
+	// Point 15m along the line from its centre, outside the line.
+	var latOut, lonOut float64
+	geodesic.WGS84.Direct(lat, lon, bearing+90, 15, &latOut, &lonOut, nil)
+
+	back := testFixes(lat, lon, bearing, 50, 20)
+	turn := back[len(back)-1].Offset + time.Second/18
+	for _, f := range testFixes(lat, lon, bearing+180, 50, 20) {
+		f.Offset += turn
+		back = append(back, f)
+	}
+
+	tests := []struct {
+		name      string
+		fixes     []Fix
+		tolerance float64
+		options   []DetectorOption
+		expected  []time.Duration
+	}{
+		{
+			name:     "centre",
+			fixes:    testFixes(lat, lon, bearing, 50, 20),
+			expected: []time.Duration{400 * time.Millisecond},
+		},
+		{
+			name:     "offset-slow",
+			fixes:    testFixes(latOff, lonOff, bearing, 10, 20),
+			expected: []time.Duration{2 * time.Second},
+		},
+		{
+			name:     "on-line",
+			fixes:    testFixes(lat, lon, bearing, 45, 20),
+			expected: []time.Duration{444 * time.Millisecond},
+		},
+		{
+			name:     "reverse",
+			fixes:    testFixes(lat, lon, bearing+180, 50, 20),
+			expected: []time.Duration{400 * time.Millisecond},
+		},
+		{
+			name:     "there-and-back",
+			fixes:    back,
+			expected: []time.Duration{400 * time.Millisecond, 1233 * time.Millisecond},
+		},
+		{
+			name:     "heading",
+			fixes:    testFixes(lat, lon, bearing+20, 50, 20),
+			options:  []DetectorOption{Heading(bearing, 30)},
+			expected: []time.Duration{400 * time.Millisecond},
+		},
+		{
+			name:    "heading-reverse",
+			fixes:   testFixes(lat, lon, bearing+180, 50, 20),
+			options: []DetectorOption{Heading(bearing, 30)},
+		},
+		{
+			name:    "heading-oblique",
+			fixes:   testFixes(lat, lon, bearing-40, 50, 20),
+			options: []DetectorOption{Heading(bearing, 30)},
+		},
+		{
+			name:     "heading-there-and-back",
+			fixes:    back,
+			options:  []DetectorOption{Heading(bearing, 30)},
+			expected: []time.Duration{400 * time.Millisecond},
+		},
+		{
+			name:  "parallel",
+			fixes: testFixes(lat, lon, bearing+90, 50, 20),
+		},
+		{
+			name:  "outside",
+			fixes: testFixes(latOut, lonOut, bearing, 50, 20),
+		},
+		{
+			name:      "outside-tolerance",
+			fixes:     testFixes(latOut, lonOut, bearing, 50, 20),
+			tolerance: 6,
+			expected:  []time.Duration{400 * time.Millisecond},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewLineDetector(NewProcessor(Tolerance(tc.tolerance)), lat1, lon1, lat2, lon2, tc.options...)
+			var got []Crossing
+			for _, f := range tc.fixes {
+				if c, ok := d.Add(f); ok {
+					got = append(got, c)
+				}
+			}
+
+			require.Len(t, got, len(tc.expected))
+			for i, c := range got {
+				require.InDelta(t, tc.expected[i], c.Offset, float64(time.Millisecond))
+				require.LessOrEqual(t, c.From.Offset, c.Offset)
+				require.GreaterOrEqual(t, c.To.Offset, c.Offset)
+			}
+		})
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		d := NewLineDetector(NewProcessor(), lat1, lon1, lat2, lon2)
+		fixes := testFixes(lat, lon, bearing, 50, 20)
+		for i, f := range fixes {
+			if i == 8 {
+				// Skip the segment which crosses.
+				d.Reset()
+			}
+			_, ok := d.Add(f)
+			require.False(t, ok)
+		}
+	})
+}
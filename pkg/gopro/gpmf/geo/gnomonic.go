@@ -224,8 +224,9 @@ func (g *Gnomonic) Intersect(
 		lat2b, lon2b,
 	)
 
-	if math.Signbit(azia1) == math.Signbit(azia2) ||
-		math.Signbit(azib1) == math.Signbit(azib2) {
+	// The intersection is between the end points of a line if the
+	// azimuths to and from it are in the same direction.
+	if !sameDirection(azia1, azia2) || !sameDirection(azib1, azib2) {
 		return 0, 0, fmt.Errorf("line a %f, %f -> %f, %f doesn't intersect with line b %f, %f -> %f, %f",
 			lat1a, lon1a,
 			lat2a, lon2a,
@@ -236,3 +237,9 @@ func (g *Gnomonic) Intersect(
 
 	return lat, lon, nil
 }
+
+// sameDirection returns true if the azimuths azi1 and azi2 in
+// degrees are less than a quarter turn apart, false otherwise.
+func sameDirection(azi1, azi2 float64) bool {
+	return math.Abs(math.Remainder(azi1-azi2, 2*halfDegrees)) < quarterDegrees
+}
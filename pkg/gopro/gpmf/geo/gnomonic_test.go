@@ -55,6 +55,58 @@ func TestIntersect(t *testing.T) {
 	}
 }
 
+func TestIntersectSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		lata1, lona1,
+		lata2, lona2,
+		latb1, lonb1,
+		latb2, lonb2 float64
+		intersects bool
+	}{
+		{
+			name:  "crossing",
+			lata1: 50.857928, lona1: -0.752664,
+			lata2: 50.857939, lona2: -0.752523,
+			latb1: 50.858006, lonb1: -0.752614,
+			latb2: 50.857828, lonb2: -0.752579,
+			intersects: true,
+		},
+		{
+			name:  "crossing-reversed",
+			lata1: 50.857939, lona1: -0.752523,
+			lata2: 50.857928, lona2: -0.752664,
+			latb1: 50.857828, lonb1: -0.752579,
+			latb2: 50.858006, lonb2: -0.752614,
+			intersects: true,
+		},
+		{
+			name:  "short",
+			lata1: 50.857928, lona1: -0.752664,
+			lata2: 50.857939, lona2: -0.752523,
+			latb1: 50.858006, lonb1: -0.752614,
+			latb2: 50.857980, lonb2: -0.752609,
+		},
+	}
+
+	gn := NewGnomonic(geodesic.WGS84)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := gn.Intersect(
+				tc.lata1, tc.lona1,
+				tc.lata2, tc.lona2,
+				tc.latb1, tc.lonb1,
+				tc.latb2, tc.lonb2,
+			)
+			if tc.intersects {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+		})
+	}
+}
+
 func TestLines(t *testing.T) {
 	g := geodesic.WGS84
 	gn := NewGnomonic(g)
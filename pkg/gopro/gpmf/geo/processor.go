@@ -1,5 +1,9 @@
 package geo
 
+import (
+	"github.com/tidwall/geodesic"
+)
+
 const (
 	// defaultRadius is the default radius used by Processor
 	// which represents the radius of the earth.
@@ -48,6 +52,9 @@ type Processor struct {
 
 	// distFunc is the function used to calculate distances between two points.
 	distFunc func(lat0, lon0, lat1, lon1, radius float64) float64
+
+	// gnomonic is the projection used to calculate intersections.
+	gnomonic *Gnomonic
 }
 
 // NewProcessor returns a new geographic Processor.
@@ -56,6 +63,7 @@ func NewProcessor(options ...Option) *Processor {
 		radius:    defaultRadius,
 		tolerance: 0.1,
 		distFunc:  distanceHaversin,
+		gnomonic:  NewGnomonic(geodesic.WGS84),
 	}
 
 	for _, f := range options {
@@ -0,0 +1,110 @@
+package geo
+
+import (
+	"time"
+)
+
+// Split represents the crossing of a sector line during a lap.
+type Split struct {
+	// Time is the time from the start of the lap to the crossing.
+	Time time.Duration
+
+	// Distance is the distance in metres travelled from the start
+	// of the lap to the crossing.
+	Distance float64
+}
+
+// Rolling represents a rolling lap, a complete lap which starts and
+// ends at the same timing line which isn't necessarily the start line.
+type Rolling struct {
+	// Line is the timing line the lap starts and ends at, 0 for the
+	// start line and n for the nth sector line.
+	Line int
+
+	// Start is the offset of the start of the lap.
+	Start time.Duration
+
+	// End is the offset of the end of the lap.
+	End time.Duration
+
+	// Time is the lap time.
+	Time time.Duration
+}
+
+// Splitter detects the crossings of an ordered list of sector lines.
+// Only a crossing of the next line in order counts, so a lap which
+// misses a sector line, for example due to a gap in the GPS data,
+// has no splits after it.
+type Splitter struct {
+	detectors []*LineDetector
+	next      int
+
+	// first is the crossing of the first sector line by the last
+	// segment added, if hasFirst.
+	first    Crossing
+	hasFirst bool
+}
+
+// NewSplitter returns a new Splitter which uses detectors to detect
+// the crossings of the sector lines in the order they are crossed.
+func NewSplitter(detectors ...*LineDetector) *Splitter {
+	return &Splitter{detectors: detectors}
+}
+
+// Add adds the next fix f returning the crossing, the index of the
+// sector line crossed and true if the segment from the previous fix
+// to f crosses the next sector line, false otherwise.
+func (s *Splitter) Add(f Fix) (Crossing, int, bool) {
+	var res Crossing
+	var ok bool
+	s.hasFirst = false
+	for i, d := range s.detectors {
+		// All detectors need every fix to track the previous one.
+		c, crossed := d.Add(f)
+		if i == 0 {
+			s.first, s.hasFirst = c, crossed
+		}
+		if crossed && i == s.next {
+			res, ok = c, true
+		}
+	}
+
+	if !ok {
+		return Crossing{}, 0, false
+	}
+
+	s.next++
+
+	return res, s.next - 1, true
+}
+
+// Len returns the number of sector lines.
+func (s *Splitter) Len() int {
+	return len(s.detectors)
+}
+
+// Restart sets the next sector line expected to the first, it should
+// be called when the start line is crossed at start. If the last
+// segment added also crossed the first sector line at or after start
+// it returns that crossing and true, as the first split of the new lap,
+// otherwise false.
+func (s *Splitter) Restart(start time.Duration) (Crossing, bool) {
+	s.next = 0
+	if !s.hasFirst || s.first.Offset < start {
+		return Crossing{}, false
+	}
+
+	s.next = 1
+
+	return s.first, true
+}
+
+// Reset resets the detectors of all the sector lines, so no crossing
+// is detected between the previous fix and the next, for example after
+// a gap in the data. The next sector line expected is unchanged.
+func (s *Splitter) Reset() {
+	s.hasFirst = false
+	for _, d := range s.detectors {
+		d.Reset()
+	}
+}
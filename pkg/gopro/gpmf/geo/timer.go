@@ -0,0 +1,510 @@
+package geo
+
+import (
+	"time"
+
+	"github.com/tidwall/geodesic"
+)
+
+// Line represents a timing line across a track, such as the start line.
+type Line struct {
+	// Latitude of the centre of the line in degrees.
+	Latitude float64
+
+	// Longitude of the centre of the line in degrees.
+	Longitude float64
+
+	// Bearing is the direction of travel across the line in degrees.
+	Bearing float64
+
+	// Distance is the distance in metres from the centre to each end.
+	Distance float64
+}
+
+// Ends returns the end points of l, to the right then the left of
+// the direction of travel.
+func (l Line) Ends() (lat1, lon1, lat2, lon2 float64) {
+	geodesic.WGS84.Direct(l.Latitude, l.Longitude, l.Bearing+quarterDegrees, l.Distance, &lat1, &lon1, nil)
+	geodesic.WGS84.Direct(l.Latitude, l.Longitude, l.Bearing-quarterDegrees, l.Distance, &lat2, &lon2, nil)
+
+	return lat1, lon1, lat2, lon2
+}
+
+// Detector returns a LineDetector for l which uses p to detect crossings.
+func (l Line) Detector(p *Processor, options ...DetectorOption) *LineDetector {
+	lat1, lon1, lat2, lon2 := l.Ends()
+
+	return NewLineDetector(p, lat1, lon1, lat2, lon2, options...)
+}
+
+// LapType represents the type of a Lap.
+type LapType int
+
+const (
+	// LapTimed is a complete lap between two start line crossings.
+	LapTimed LapType = iota
+
+	// LapOut is the partial lap from the first fix to the first
+	// start line crossing, typically leaving the pits.
+	LapOut
+
+	// LapIn is the partial lap from the last start line crossing
+	// to the last fix, typically returning to the pits. For point
+	// to point timing it's a run which didn't reach the finish line.
+	LapIn
+
+	// LapRun is a complete point to point run from a start line
+	// crossing to a finish line crossing.
+	LapRun
+)
+
+// Timed returns true if t is a complete lap or run.
+func (t LapType) Timed() bool {
+	return t == LapTimed || t == LapRun
+}
+
+// String implements fmt.Stringer.
+func (t LapType) String() string {
+	switch t {
+	case LapOut:
+		return "out"
+	case LapIn:
+		return "in"
+	case LapRun:
+		return "run"
+	default:
+		return "lap"
+	}
+}
+
+// Lap represents a lap, or the partial laps before the first and
+// after the last start line crossing, or a point to point run.
+type Lap struct {
+	// Number is the lap number, the out lap is lap 0, or the run
+	// number starting at 1.
+	Number int
+
+	// Type is the type of the lap.
+	Type LapType
+
+	// Start is the offset of the start of the lap.
+	Start time.Duration
+
+	// End is the offset of the end of the lap.
+	End time.Duration
+
+	// Time is the lap time.
+	Time time.Duration
+
+	// Delta is the difference between Time and the best lap time,
+	// only set for timed laps and runs.
+	Delta time.Duration
+
+	// MaxSpeed is the maximum speed in m/s.
+	MaxSpeed float64
+
+	// Distance is the distance travelled in metres.
+	Distance float64
+
+	// Splits are the crossings of the sector lines in order, the out
+	// lap has none.
+	Splits []Split
+
+	// Sectors are the times of each complete sector, which for a timed
+	// lap or run which crossed every sector line is one more than Splits.
+	Sectors []time.Duration
+}
+
+// TimerOption is an option for a Timer.
+type TimerOption func(*Timer)
+
+// MinLapTime sets the minimum lap time, start line crossings less
+// than d after the previous crossing are ignored so GPS noise or
+// a car stopped on the line doesn't result in extra laps.
+// Default: 0, all crossings are counted.
+func MinLapTime(d time.Duration) TimerOption {
+	return func(t *Timer) {
+		t.minLapTime = d
+	}
+}
+
+// Sectors sets the sector lines in the order they are crossed after
+// the start line, each lap is split into one more sector than lines.
+// Default: none.
+func Sectors(lines ...Line) TimerOption {
+	return func(t *Timer) {
+		t.lines = lines
+	}
+}
+
+// HeadingTolerance sets the tolerance in degrees between the direction
+// of travel and the bearing of a line for a crossing to count.
+// Default: DefaultHeadingTolerance.
+func HeadingTolerance(deg float64) TimerOption {
+	return func(t *Timer) {
+		t.headingTolerance = deg
+	}
+}
+
+// Finish sets a finish line separate from the start line for point
+// to point timing, such as a hillclimb or rally stage, where each run
+// is timed from a start line crossing to the next finish line crossing.
+// Finish line crossings less than MinLapTime after the start are ignored.
+// Default: none, laps are timed between start line crossings.
+func Finish(line Line) TimerOption {
+	return func(t *Timer) {
+		t.finishLine = &line
+	}
+}
+
+// AnyDirection counts crossings of the lines in either direction
+// instead of only those in the direction of the line's bearing.
+func AnyDirection() TimerOption {
+	return func(t *Timer) {
+		t.anyDirection = true
+	}
+}
+
+// Timer calculates laps from consecutive fixes using the crossings
+// of a start line, and optionally sector lines.
+type Timer struct {
+	p                *Processor
+	detector         *LineDetector
+	splitter         *Splitter
+	lines            []Line
+	finishLine       *Line
+	finisher         *LineDetector
+	minLapTime       time.Duration
+	headingTolerance float64
+	anyDirection     bool
+
+	// laps are the completed laps.
+	laps []Lap
+
+	// current is the lap in progress.
+	current Lap
+
+	// prev is the previous fix, if hasPrev.
+	prev    Fix
+	hasPrev bool
+
+	// crossed is the offset of the last counted crossing, if any.
+	crossed    time.Duration
+	hasCrossed bool
+
+	// running is true if a point to point run is in progress.
+	running bool
+}
+
+// NewTimer returns a new Timer which uses p to detect crossings
+// of the start line. Only crossings in the direction of each line's
+// bearing count unless AnyDirection is used.
+func NewTimer(p *Processor, start Line, options ...TimerOption) *Timer {
+	t := &Timer{
+		p:                p,
+		current:          Lap{Type: LapOut},
+		headingTolerance: DefaultHeadingTolerance,
+	}
+
+	for _, o := range options {
+		o(t)
+	}
+
+	t.detector = t.lineDetector(start)
+	detectors := make([]*LineDetector, len(t.lines))
+	for i, l := range t.lines {
+		detectors[i] = t.lineDetector(l)
+	}
+	t.splitter = NewSplitter(detectors...)
+	if t.finishLine != nil {
+		t.finisher = t.lineDetector(*t.finishLine)
+	}
+
+	return t
+}
+
+// lineDetector returns the LineDetector for l.
+func (t *Timer) lineDetector(l Line) *LineDetector {
+	if t.anyDirection {
+		return l.Detector(t.p)
+	}
+
+	return l.Detector(t.p, Heading(l.Bearing, t.headingTolerance))
+}
+
+// Add adds the next fix f returning the start line crossing and true
+// if it completed a lap, false otherwise. For point to point timing
+// it returns the start line crossing which starts a run and the
+// finish line crossing which completes it.
+func (t *Timer) Add(f Fix) (Crossing, bool) {
+	prev, hasPrev := t.prev, t.hasPrev
+	t.prev, t.hasPrev = f, true
+	c, ok := t.detector.Add(f)
+	sc, _, split := t.splitter.Add(f)
+	var fc Crossing
+	var finished bool
+	if t.finisher != nil {
+		fc, finished = t.finisher.Add(f)
+	}
+
+	if !hasPrev {
+		t.current.Start = f.Offset
+		t.current.MaxSpeed = f.Speed
+		return Crossing{}, false
+	}
+
+	if t.finisher != nil {
+		return t.run(prev, f, c, ok, sc, split, fc, finished)
+	}
+
+	if ok && t.hasCrossed && c.Offset-t.crossed < t.minLapTime {
+		ok = false
+	}
+
+	// Splits only count once the start line has been crossed.
+	if split && t.hasCrossed && (!ok || sc.Offset < c.Offset) {
+		t.split(prev, sc)
+	}
+
+	if !ok {
+		t.extend(prev, f)
+		return Crossing{}, false
+	}
+
+	t.current.Distance += t.p.Distance(prev.Latitude, prev.Longitude, c.Latitude, c.Longitude)
+	t.finish(c.Offset)
+	t.begin(t.current.Number+1, LapTimed, c, f)
+
+	return c, true
+}
+
+// Reset resets the line detectors, so no crossing is detected between
+// the previous fix and the next, for example after a gap in the data or
+// skipping fixes with a poor GPS lock. Laps, including the lap in
+// progress, are unaffected.
+func (t *Timer) Reset() {
+	t.detector.Reset()
+	t.splitter.Reset()
+	if t.finisher != nil {
+		t.finisher.Reset()
+	}
+}
+
+// run handles the fix f for point to point timing, where start, sc and
+// fc are the crossings of the start, next sector and finish lines by
+// the segment from prev to f if started, split and finished are true.
+func (t *Timer) run(
+	prev, f Fix,
+	start Crossing, started bool,
+	sc Crossing, split bool,
+	fc Crossing, finished bool,
+) (Crossing, bool) {
+	if !t.running {
+		if !started {
+			return Crossing{}, false
+		}
+
+		t.begin(len(t.laps)+1, LapRun, start, f)
+		t.running = true
+
+		return start, true
+	}
+
+	if finished && fc.Offset-t.current.Start < t.minLapTime {
+		finished = false
+	}
+
+	if split && (!finished || sc.Offset < fc.Offset) {
+		t.split(prev, sc)
+	}
+
+	if !finished {
+		t.extend(prev, f)
+		return Crossing{}, false
+	}
+
+	t.current.Distance += t.p.Distance(prev.Latitude, prev.Longitude, fc.Latitude, fc.Longitude)
+	t.finish(fc.Offset)
+	t.running = false
+
+	return fc, true
+}
+
+// begin starts a new current lap of type typ numbered n at the start
+// line crossing c by the segment ending at f, including the crossing
+// of the first sector line if it's after c in the same segment.
+func (t *Timer) begin(n int, typ LapType, c Crossing, f Fix) {
+	t.current = Lap{
+		Number:   n,
+		Type:     typ,
+		Start:    c.Offset,
+		MaxSpeed: f.Speed,
+	}
+	t.crossed, t.hasCrossed = c.Offset, true
+	if sc, ok := t.splitter.Restart(c.Offset); ok {
+		t.split(Fix{Latitude: c.Latitude, Longitude: c.Longitude}, sc)
+	}
+	t.current.Distance = t.p.Distance(c.Latitude, c.Longitude, f.Latitude, f.Longitude)
+}
+
+// split adds the sector line crossing c by the segment from prev to the
+// current lap.
+func (t *Timer) split(prev Fix, c Crossing) {
+	t.current.Splits = append(t.current.Splits, Split{
+		Time:     c.Offset - t.current.Start,
+		Distance: t.current.Distance + t.p.Distance(prev.Latitude, prev.Longitude, c.Latitude, c.Longitude),
+	})
+}
+
+// extend extends the current lap by the segment from prev to f.
+func (t *Timer) extend(prev, f Fix) {
+	t.current.Distance += t.p.Distance(prev.Latitude, prev.Longitude, f.Latitude, f.Longitude)
+	t.current.MaxSpeed = max(t.current.MaxSpeed, f.Speed)
+}
+
+// finish completes the current lap at end.
+func (t *Timer) finish(end time.Duration) {
+	t.current.End = end
+	t.current.Time = end - t.current.Start
+	t.laps = append(t.laps, t.current)
+}
+
+// Laps returns the laps, starting with the out lap and ending with
+// the in lap if there are fixes after the last crossing. It returns
+// nil if the start line hasn't been crossed.
+// For point to point timing it returns the runs, ending with the in
+// lap if the last run didn't reach the finish line.
+func (t *Timer) Laps() []Lap {
+	if !t.hasCrossed {
+		return nil
+	}
+
+	laps := make([]Lap, len(t.laps), len(t.laps)+1)
+	copy(laps, t.laps)
+	if (t.finisher == nil || t.running) && t.prev.Offset > t.crossed {
+		in := t.current
+		in.Type = LapIn
+		in.End = t.prev.Offset
+		in.Time = in.End - in.Start
+		laps = append(laps, in)
+	}
+
+	best, hasBest := Best(laps)
+	for i, l := range laps {
+		if l.Type.Timed() && hasBest {
+			laps[i].Delta = l.Time - best.Time
+		}
+		laps[i].Sectors = t.sectors(l)
+	}
+
+	return laps
+}
+
+// sectors returns the times of the complete sectors of l.
+func (t *Timer) sectors(l Lap) []time.Duration {
+	if len(l.Splits) == 0 && !l.Type.Timed() {
+		return nil
+	}
+
+	sectors := make([]time.Duration, 0, len(l.Splits)+1)
+	var prev time.Duration
+	for _, s := range l.Splits {
+		sectors = append(sectors, s.Time-prev)
+		prev = s.Time
+	}
+
+	if l.Type.Timed() && len(l.Splits) == t.splitter.Len() {
+		sectors = append(sectors, l.Time-prev)
+	}
+
+	return sectors
+}
+
+// TheoreticalBest returns the sum of the best time of each sector
+// of all laps and true, false if any sector hasn't been completed.
+// Without sector lines it's the same as the best lap time.
+func (t *Timer) TheoreticalBest() (time.Duration, bool) {
+	best := make([]time.Duration, t.splitter.Len()+1)
+	for _, l := range t.Laps() {
+		for i, s := range l.Sectors {
+			if best[i] == 0 || s < best[i] {
+				best[i] = s
+			}
+		}
+	}
+
+	var total time.Duration
+	for _, s := range best {
+		if s == 0 {
+			return 0, false
+		}
+		total += s
+	}
+
+	return total, true
+}
+
+// BestRolling returns the fastest rolling lap and true, false if
+// there are none. A rolling lap can start at any timing line and
+// must cross every other timing line in order before returning to
+// it, so it's never slower than the best lap. There are no rolling
+// laps for point to point timing.
+func (t *Timer) BestRolling() (Rolling, bool) {
+	if t.finisher != nil {
+		return Rolling{}, false
+	}
+
+	// Line crossings in order with their offsets.
+	type event struct {
+		line   int
+		offset time.Duration
+	}
+
+	var events []event
+	for _, l := range t.Laps() {
+		if l.Type != LapOut {
+			events = append(events, event{offset: l.Start})
+		}
+		for i, s := range l.Splits {
+			events = append(events, event{line: i + 1, offset: l.Start + s.Time})
+		}
+	}
+
+	n := t.splitter.Len() + 1
+	var best Rolling
+	var ok bool
+	for i := 0; i+n < len(events); i++ {
+		start := events[i]
+		complete := true
+		for j := 1; j <= n && complete; j++ {
+			complete = events[i+j].line == (start.line+j)%n
+		}
+
+		end := events[i+n]
+		if complete && (!ok || end.offset-start.offset < best.Time) {
+			best = Rolling{
+				Line:  start.line,
+				Start: start.offset,
+				End:   end.offset,
+				Time:  end.offset - start.offset,
+			}
+			ok = true
+		}
+	}
+
+	return best, ok
+}
+
+// Best returns the fastest timed lap or run and true, false if there
+// are none.
+func Best(laps []Lap) (Lap, bool) {
+	var best Lap
+	var ok bool
+	for _, l := range laps {
+		if l.Type.Timed() && (!ok || l.Time < best.Time) {
+			best, ok = l, true
+		}
+	}
+
+	return best, ok
+}
@@ -0,0 +1,303 @@
+package geo
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/geodesic"
+)
+
+// testCircuit returns the fixes at 18Hz of a vehicle driving clockwise
+// around a circle of radius centred on (lat, lon) from angle degrees,
+// where north is 0, for the given number of turns, with the speed in
+// m/s for each complete turn from north given by speeds.
+func testCircuit(lat, lon, radius, angle, turns float64, speeds ...float64) []Fix {
+	var fixes []Fix
+	interval := time.Second / 18
+	end := angle + turns*2*halfDegrees
+	for i := 0; angle <= end; i++ {
+		turn := int(math.Floor(angle / (2 * halfDegrees)))
+		speed := speeds[min(max(turn, 0), len(speeds)-1)]
+		f := Fix{Speed: speed, Offset: time.Duration(i) * interval}
+		geodesic.WGS84.Direct(lat, lon, angle, radius, &f.Latitude, &f.Longitude, nil)
+		fixes = append(fixes, f)
+		angle += speed * interval.Seconds() / radius / radians
+	}
+
+	return fixes
+}
+
+func TestTimer(t *testing.T) {
+	const lat, lon, radius = 50.857933, -0.752594, 100.0
+	var startLat, startLon float64
+	geodesic.WGS84.Direct(lat, lon, 0, radius, &startLat, &startLon, nil)
+	start := Line{Latitude: startLat, Longitude: startLon, Bearing: 90, Distance: 10}
+
+	lap := func(speed float64) time.Duration {
+		return time.Duration(2 * math.Pi * radius / speed * float64(time.Second))
+	}
+
+	// Quarter of a turn before the line, three laps and a quarter after.
+	fixes := testCircuit(lat, lon, radius, -90, 3.5, 20, 25, 22, 10)
+	timer := NewTimer(NewProcessor(), start)
+	var crossings int
+	for _, f := range fixes {
+		if _, ok := timer.Add(f); ok {
+			crossings++
+		}
+	}
+	require.Equal(t, 4, crossings)
+
+	laps := timer.Laps()
+	require.Len(t, laps, 5)
+	types := []LapType{LapOut, LapTimed, LapTimed, LapTimed, LapIn}
+	speeds := []float64{20, 20, 25, 22, 10}
+	for i, l := range laps {
+		require.Equal(t, i, l.Number)
+		require.Equal(t, types[i], l.Type)
+		require.Equal(t, l.End-l.Start, l.Time)
+		require.InDelta(t, speeds[i], l.MaxSpeed, 0.001, "lap %d", i)
+		if i > 0 {
+			require.Equal(t, laps[i-1].End, l.Start)
+		}
+	}
+
+	// Speed changes at the line part way between fixes so allow for
+	// the error from the fix before the line determining the speed.
+	require.InDelta(t, lap(20)/4, laps[0].Time, float64(10*time.Millisecond))
+	for i, speed := range []float64{20, 25, 22} {
+		l := laps[i+1]
+		require.InDelta(t, lap(speed), l.Time, float64(10*time.Millisecond), "lap %d", l.Number)
+		require.InDelta(t, 2*math.Pi*radius, l.Distance, 0.5, "lap %d", l.Number)
+		require.InDelta(t, l.Time-laps[2].Time, l.Delta, 1)
+	}
+	require.Equal(t, fixes[len(fixes)-1].Offset, laps[4].End)
+	require.Zero(t, laps[0].Delta)
+
+	best, ok := Best(laps)
+	require.True(t, ok)
+	require.Equal(t, laps[2], best)
+
+	t.Run("min-lap-time", func(t *testing.T) {
+		timer := NewTimer(NewProcessor(), start, MinLapTime(lap(20)+time.Second))
+		for _, f := range testCircuit(lat, lon, radius, -90, 3.5, 20) {
+			timer.Add(f)
+		}
+
+		// Every other crossing is ignored.
+		laps := timer.Laps()
+		require.Len(t, laps, 3)
+		require.InDelta(t, 2*lap(20), laps[1].Time, float64(5*time.Millisecond))
+		require.Equal(t, LapIn, laps[2].Type)
+	})
+
+	t.Run("sectors", func(t *testing.T) {
+		// line returns a timing line at angle on the circle.
+		line := func(angle float64) Line {
+			l := Line{Bearing: angle + quarterDegrees, Distance: 10}
+			geodesic.WGS84.Direct(lat, lon, angle, radius, &l.Latitude, &l.Longitude, nil)
+			return l
+		}
+
+		// sector returns the time to travel deg degrees at speed.
+		sector := func(deg, speed float64) time.Duration {
+			return time.Duration(deg / 360 * float64(lap(speed)))
+		}
+
+		// Start line at the south with the speed changing at the north,
+		// half way round each lap and part way through the second sector.
+		timer := NewTimer(NewProcessor(), line(180), Sectors(line(300), line(60)))
+		for _, f := range testCircuit(lat, lon, radius, 90, 3.5, 20, 25, 22, 10) {
+			timer.Add(f)
+		}
+
+		laps := timer.Laps()
+		require.Len(t, laps, 5)
+		require.Empty(t, laps[0].Splits)
+		require.Empty(t, laps[0].Sectors)
+		require.Empty(t, laps[4].Splits)
+		require.Empty(t, laps[4].Sectors)
+
+		expected := [][]time.Duration{
+			{sector(120, 20), sector(60, 20) + sector(60, 25), sector(120, 25)},
+			{sector(120, 25), sector(60, 25) + sector(60, 22), sector(120, 22)},
+			{sector(120, 22), sector(60, 22) + sector(60, 10), sector(120, 10)},
+		}
+		for i, sectors := range expected {
+			l := laps[i+1]
+			require.Len(t, l.Splits, 2)
+			require.Len(t, l.Sectors, 3)
+			for j, s := range sectors {
+				// Allow for the fix before the speed change overshooting
+				// at the old speed, which is up to 70ms at 22 to 10 m/s.
+				require.InDelta(t, s, l.Sectors[j], float64(70*time.Millisecond), "lap %d sector %d", l.Number, j)
+			}
+			require.InDelta(t, 2*math.Pi*radius/3, l.Splits[0].Distance, 0.5)
+			require.InDelta(t, 4*math.Pi*radius/3, l.Splits[1].Distance, 0.5)
+			require.Equal(t, l.Splits[0].Time, l.Sectors[0])
+		}
+
+		best, ok := timer.TheoreticalBest()
+		require.True(t, ok)
+		require.InDelta(t, sector(300, 25)+sector(60, 22), best, float64(10*time.Millisecond))
+		lapBest, ok := Best(laps)
+		require.True(t, ok)
+		require.Less(t, best, lapBest.Time)
+
+		rolling, ok := timer.BestRolling()
+		require.True(t, ok)
+		require.Equal(t, 2, rolling.Line)
+		require.InDelta(t, sector(270, 20)+sector(60, 25), rolling.Start, float64(10*time.Millisecond))
+		require.InDelta(t, sector(300, 25)+sector(60, 22), rolling.Time, float64(10*time.Millisecond))
+		require.Equal(t, rolling.End-rolling.Start, rolling.Time)
+
+		t.Run("same-segment", func(t *testing.T) {
+			// Sector line closer to the start line than the distance
+			// between fixes, so the first start line crossing and
+			// the sector line crossing are in the same segment.
+			timer := NewTimer(NewProcessor(), line(180), Sectors(line(180.2)))
+			for _, f := range testCircuit(lat, lon, radius, 90, 3.5, 20) {
+				timer.Add(f)
+			}
+
+			laps := timer.Laps()
+			require.Len(t, laps, 5)
+			for _, l := range laps[1:] {
+				require.Len(t, l.Splits, 1, "lap %d", l.Number)
+				require.InDelta(t, sector(0.2, 20), l.Splits[0].Time, float64(time.Millisecond), "lap %d", l.Number)
+				require.InDelta(t, 2*math.Pi*radius*0.2/360, l.Splits[0].Distance, 0.05, "lap %d", l.Number)
+			}
+		})
+	})
+
+	t.Run("no-sectors", func(t *testing.T) {
+		theoretical, ok := timer.TheoreticalBest()
+		require.True(t, ok)
+		require.Equal(t, best.Time, theoretical)
+
+		rolling, ok := timer.BestRolling()
+		require.True(t, ok)
+		require.Zero(t, rolling.Line)
+		require.Equal(t, best.Time, rolling.Time)
+		require.Equal(t, best.Start, rolling.Start)
+	})
+
+	t.Run("direction", func(t *testing.T) {
+		// Start line facing the opposite direction.
+		reverse := start
+		reverse.Bearing += halfDegrees
+
+		timer := NewTimer(NewProcessor(), reverse)
+		for _, f := range fixes {
+			timer.Add(f)
+		}
+		require.Nil(t, timer.Laps())
+
+		timer = NewTimer(NewProcessor(), reverse, AnyDirection())
+		for _, f := range fixes {
+			timer.Add(f)
+		}
+		require.Len(t, timer.Laps(), 5)
+
+		// Fixes are less than a degree of arc apart so the direction of
+		// travel between them at the line is about 10 degrees off.
+		oblique := start
+		oblique.Bearing += 10
+		timer = NewTimer(NewProcessor(), oblique, HeadingTolerance(5))
+		for _, f := range fixes {
+			timer.Add(f)
+		}
+		require.Nil(t, timer.Laps())
+	})
+
+	t.Run("point-to-point", func(t *testing.T) {
+		// Finish line half way round at the south with a sector line
+		// at the east.
+		var finish, sector Line
+		geodesic.WGS84.Direct(lat, lon, 180, radius, &finish.Latitude, &finish.Longitude, nil)
+		finish.Bearing, finish.Distance = 270, 10
+		geodesic.WGS84.Direct(lat, lon, 90, radius, &sector.Latitude, &sector.Longitude, nil)
+		sector.Bearing, sector.Distance = 180, 10
+
+		timer := NewTimer(NewProcessor(), start, Finish(finish), Sectors(sector))
+		var crossings int
+		for _, f := range fixes {
+			if _, ok := timer.Add(f); ok {
+				crossings++
+			}
+		}
+
+		// Three complete runs and a fourth which doesn't finish.
+		require.Equal(t, 7, crossings)
+		laps := timer.Laps()
+		require.Len(t, laps, 4)
+		for i, speed := range []float64{20, 25, 22} {
+			l := laps[i]
+			require.Equal(t, i+1, l.Number)
+			require.Equal(t, LapRun, l.Type)
+			require.InDelta(t, lap(speed)/2, l.Time, float64(10*time.Millisecond), "run %d", l.Number)
+			require.InDelta(t, math.Pi*radius, l.Distance, 0.5, "run %d", l.Number)
+			require.InDelta(t, l.Time-laps[1].Time, l.Delta, 1)
+			require.Len(t, l.Splits, 1)
+			require.Len(t, l.Sectors, 2)
+			require.InDelta(t, lap(speed)/4, l.Sectors[0], float64(10*time.Millisecond), "run %d", l.Number)
+		}
+
+		in := laps[3]
+		require.Equal(t, LapIn, in.Type)
+		require.Equal(t, fixes[len(fixes)-1].Offset, in.End)
+		// Speed changes from 22 to 10 m/s at the start line.
+		require.InDelta(t, lap(10)/4, in.Time, float64(70*time.Millisecond))
+		require.Empty(t, in.Sectors)
+
+		best, ok := Best(laps)
+		require.True(t, ok)
+		require.Equal(t, laps[1], best)
+
+		theoretical, ok := timer.TheoreticalBest()
+		require.True(t, ok)
+		require.InDelta(t, lap(25)/2, theoretical, float64(10*time.Millisecond))
+
+		_, ok = timer.BestRolling()
+		require.False(t, ok)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		// A reset between the fixes either side of the line loses the crossing.
+		timer := NewTimer(NewProcessor(), start)
+		var crossings int
+		var reset bool
+		for i, f := range fixes {
+			if !reset && i > 0 && fixes[i-1].Longitude < startLon && f.Longitude >= startLon {
+				timer.Reset()
+				reset = true
+			}
+			if _, ok := timer.Add(f); ok {
+				crossings++
+			}
+		}
+		require.True(t, reset)
+		require.Equal(t, 3, crossings)
+
+		laps := timer.Laps()
+		require.Len(t, laps, 4)
+		require.Equal(t, LapOut, laps[0].Type)
+		require.InDelta(t, lap(20)/4+lap(20), laps[0].Time, float64(10*time.Millisecond))
+	})
+
+	t.Run("no-crossings", func(t *testing.T) {
+		timer := NewTimer(NewProcessor(), start)
+		for _, f := range testCircuit(lat, lon, radius, 10, 0.5, 20) {
+			timer.Add(f)
+		}
+		require.Nil(t, timer.Laps())
+		_, ok := Best(timer.Laps())
+		require.False(t, ok)
+		_, ok = timer.TheoreticalBest()
+		require.False(t, ok)
+		_, ok = timer.BestRolling()
+		require.False(t, ok)
+	})
+}
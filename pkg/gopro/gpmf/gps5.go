@@ -13,13 +13,45 @@ type GPSData []GPS
 // offsets implements offseter.
 func (d GPSData) offsets(start, end time.Duration) {
 	offsets(start, end, d, func(i int, val time.Duration) {
-		v := d[i]
-		v.Offset = val
-		d[i] = v
+		d[i].Offset = val
 	})
 }
 
-// GPS represents GPS5 data.
+// times sets the Time of samples which don't have one from utc, the
+// GPSU time of the payload, which is the time at offset start.
+func (d GPSData) times(utc time.Time, start time.Duration) {
+	for i := range d {
+		if d[i].Time.IsZero() {
+			d[i].Time = utc.Add(d[i].Offset - start)
+		}
+	}
+}
+
+// TimeAt returns the UTC time at offset calculated from the sample
+// with a known time closest to it, false if no sample has a time.
+func (d GPSData) TimeAt(offset time.Duration) (time.Time, bool) {
+	var closest *GPS
+	var diff time.Duration
+	for i := range d {
+		v := &d[i]
+		if v.Time.IsZero() {
+			continue
+		}
+
+		delta := (offset - v.Offset).Abs()
+		if closest == nil || delta < diff {
+			closest, diff = v, delta
+		}
+	}
+
+	if closest == nil {
+		return time.Time{}, false
+	}
+
+	return closest.Time.Add(offset - closest.Offset), true
+}
+
+// GPS represents GPS5 or GPS9 data.
 type GPS struct {
 	Latitude  float64
 	Longitude float64
@@ -27,17 +59,37 @@ type GPS struct {
 	Speed     float64
 	Speed3D   float64
 	Offset    time.Duration
+
+	// Time is the UTC time of the sample. For GPS9 this is recorded
+	// per sample, for GPS5 it's calculated from the payload GPSU time
+	// and the samples offset within the payload once offsets are set.
+	Time time.Time
+
+	// DoP is the dilution of precision of the sample, for GPS5
+	// this is the value for the whole stream if known.
+	DoP GPSDoP
+
+	// Fix is the fix of the sample, for GPS5 this is the value
+	// for the whole stream if known.
+	Fix GPSFix
 }
 
 func (g GPS) String() string {
-	return fmt.Sprintf("pos: %.7f,%.7f, alt: %.2f, speed: %.2f, speed3d: %.2f off: %s",
+	s := fmt.Sprintf("pos: %.7f,%.7f, alt: %.2f, speed: %.2f, speed3d: %.2f off: %s dop: %.2f fix: %d",
 		g.Latitude,
 		g.Longitude,
 		g.Altitude,
 		g.Speed,
 		g.Speed3D,
 		g.Offset,
+		g.DoP,
+		g.Fix,
 	)
+	if !g.Time.IsZero() {
+		s += " time: " + g.Time.Format(time.RFC3339Nano)
+	}
+
+	return s
 }
 
 // MarshalZerologObject implements zerolog.LogObjectMarshaler.
@@ -47,11 +99,18 @@ func (g GPS) MarshalZerologObject(e *zerolog.Event) {
 		Float64("altitude", g.Altitude).
 		Float64("speed", g.Speed).
 		Float64("speed3d", g.Speed3D).
-		Str("offset", g.Offset.String())
+		Str("offset", g.Offset.String()).
+		Float64("dop", float64(g.DoP)).
+		Uint32("fix", uint32(g.Fix))
+	if !g.Time.IsZero() {
+		e.Time("time", g.Time)
+	}
 }
 
 func parseGPS(e *Element) error {
 	e.initMetadata()
+	dop, _ := e.Metadata[friendlyName(KeyGSPDoP)].(GPSDoP)
+	fix, _ := e.Metadata[friendlyName(KeyGPSFix)].(GPSFix)
 	return floatType[GPSData](e, 5, func(vals []float64) GPS {
 		return GPS{
 			Latitude:  vals[0],
@@ -59,6 +118,8 @@ func parseGPS(e *Element) error {
 			Altitude:  vals[2],
 			Speed:     vals[3],
 			Speed3D:   vals[4],
+			DoP:       dop,
+			Fix:       fix,
 		}
 	})
 }
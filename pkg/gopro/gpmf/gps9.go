@@ -0,0 +1,65 @@
+package gpmf
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// gps9Epoch is the epoch of GPS9 day values.
+var gps9Epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// parseGPS9 parses GPS9 data which in addition to the GPS5 values
+// has a per sample days since 2000, seconds since midnight, DOP and fix.
+func parseGPS9(e *Element) error {
+	e.initMetadata()
+	if d, ok := e.Data.(StructData); ok {
+		vals, err := d.floats()
+		if err != nil {
+			return fmt.Errorf("gps9: %w", err)
+		}
+		e.Data = vals
+	}
+
+	return floatType[GPSData](e, 9, func(vals []float64) GPS {
+		secs := time.Duration(math.Round(vals[6]*1e6)) * time.Microsecond
+		return GPS{
+			Latitude:  vals[0],
+			Longitude: vals[1],
+			Altitude:  vals[2],
+			Speed:     vals[3],
+			Speed3D:   vals[4],
+			Time:      gps9Epoch.AddDate(0, 0, int(vals[5])).Add(secs),
+			DoP:       GPSDoP(vals[7]),
+			Fix:       GPSFix(vals[8]),
+		}
+	})
+}
+
+// GPSSamples returns the GPS samples contained in elems.
+// Cameras such as the HERO11 record both GPS5 and GPS9 streams, in
+// which case only the more detailed GPS9 samples are returned.
+func GPSSamples(elems []*Element) GPSData {
+	var gps5, gps9 GPSData
+	Walk(elems, func(e *Element) error { //nolint: errcheck // Never returns an error.
+		d, ok := e.Data.(GPSData)
+		if !ok {
+			return nil
+		}
+
+		switch e.Header.FourCC() {
+		case KeyGPS9:
+			gps9 = append(gps9, d...)
+		default:
+			gps5 = append(gps5, d...)
+		}
+
+		return nil
+	})
+
+	if len(gps9) != 0 {
+		return gps9
+	}
+
+	return gps5
+}
@@ -0,0 +1,138 @@
+package gpmf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// be returns the big endian encoding of vals.
+func be(t *testing.T, vals ...any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, v := range vals {
+		require.NoError(t, binary.Write(&buf, byteOrder, v))
+	}
+	return buf.Bytes()
+}
+
+func TestGPS9(t *testing.T) {
+	def := "lllllllSS"
+	typ := klv(KeyTypeDef, String, 1, uint16(len(def)), []byte(def))
+	scal := klv(KeyScale, Int32, 4, 9, be(t,
+		int32(10000000), int32(10000000), int32(1000), int32(1000), int32(100),
+		int32(1), int32(1000), int32(100), int32(1),
+	))
+	raw := be(t,
+		int32(514321234), int32(-11234567), int32(123456), int32(25500), int32(2560),
+		int32(8766), int32(45296789), uint16(152), uint16(3),
+		int32(514321334), int32(-11234467), int32(123400), int32(25600), int32(2570),
+		int32(8766), int32(45296889), uint16(98), uint16(2),
+	)
+	gps9 := klv(KeyGPS9, Complex, 32, 2, raw)
+
+	scal5 := klv(KeyScale, Int32, 4, 5, be(t,
+		int32(10000000), int32(10000000), int32(1000), int32(1000), int32(100),
+	))
+	gps5 := klv(KeyGPS, Int32, 20, 1, be(t,
+		int32(514321234), int32(-11234567), int32(123456), int32(25500), int32(2560),
+	))
+	dop := klv(KeyGSPDoP, Uint16, 2, 1, be(t, uint16(250)))
+	fix := klv(KeyGPSFix, Uint32, 4, 1, be(t, uint32(3)))
+
+	stream := func(elems ...[]byte) []byte {
+		b := bytes.Join(elems, nil)
+		return klv(KeyStream, Nested, 1, uint16(len(b)), b)
+	}
+	devc := func(elems ...[]byte) []byte {
+		b := bytes.Join(elems, nil)
+		return klv(KeyDevice, Nested, 1, uint16(len(b)), b)
+	}
+
+	read := func(t *testing.T, b []byte) []*Element {
+		t.Helper()
+		res, err := NewReader().Read(bytes.NewReader(b))
+		require.NoError(t, err)
+		return res
+	}
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected9 := GPSData{
+		{
+			Latitude:  51.4321234,
+			Longitude: -1.1234567,
+			Altitude:  123.456,
+			Speed:     25.5,
+			Speed3D:   25.6,
+			Time:      day.Add(12*time.Hour + 34*time.Minute + 56*time.Second + 789*time.Millisecond),
+			DoP:       1.52,
+			Fix:       GPS3DLock,
+		},
+		{
+			Latitude:  51.4321334,
+			Longitude: -1.1234467,
+			Altitude:  123.4,
+			Speed:     25.6,
+			Speed3D:   25.7,
+			Time:      day.Add(12*time.Hour + 34*time.Minute + 56*time.Second + 889*time.Millisecond),
+			DoP:       0.98,
+			Fix:       GPS2DLock,
+		},
+	}
+
+	t.Run("gps9", func(t *testing.T) {
+		res := read(t, stream(typ, scal, gps9))
+		d, ok := res[0].Nested[2].Data.(GPSData)
+		require.True(t, ok)
+		require.Len(t, d, len(expected9))
+		for i, v := range expected9 {
+			require.InDelta(t, v.Latitude, d[i].Latitude, 1e-9)
+			require.InDelta(t, v.Longitude, d[i].Longitude, 1e-9)
+			require.InDelta(t, v.Altitude, d[i].Altitude, 1e-9)
+			require.InDelta(t, v.Speed, d[i].Speed, 1e-9)
+			require.InDelta(t, v.Speed3D, d[i].Speed3D, 1e-9)
+			require.Equal(t, v.Time, d[i].Time)
+			require.InDelta(t, float64(v.DoP), float64(d[i].DoP), 1e-9)
+			require.Equal(t, v.Fix, d[i].Fix)
+		}
+	})
+
+	t.Run("gps5", func(t *testing.T) {
+		res := read(t, stream(fix, dop, scal5, gps5))
+		d, ok := res[0].Nested[3].Data.(GPSData)
+		require.True(t, ok)
+		require.Len(t, d, 1)
+		require.Equal(t, GPSDoP(2.5), d[0].DoP)
+		require.Equal(t, GPS3DLock, d[0].Fix)
+		require.True(t, d[0].Time.IsZero())
+	})
+
+	t.Run("gps5-time", func(t *testing.T) {
+		utc := time.Date(2022, 6, 7, 11, 0, 56, 500000000, time.UTC)
+		gpsu := klv(KeyGPSTime, Date, 16, 1, []byte(utc.Format(dateFormat)))
+		gps5 := klv(KeyGPS, Int32, 20, 2, be(t,
+			int32(514321234), int32(-11234567), int32(123456), int32(25500), int32(2560),
+			int32(514321334), int32(-11234467), int32(123400), int32(25600), int32(2570),
+		))
+		res := read(t, stream(gpsu, scal5, gps5))
+		require.NoError(t, Walk(res, newOffsetWalker(1000, 2000, time.Millisecond, nil).walk))
+		d, ok := res[0].Nested[2].Data.(GPSData)
+		require.True(t, ok)
+		require.Len(t, d, 2)
+		require.Equal(t, utc, d[0].Time)
+		require.Equal(t, utc.Add(500*time.Millisecond), d[1].Time)
+	})
+
+	t.Run("samples", func(t *testing.T) {
+		res := read(t, devc(stream(fix, dop, scal5, gps5), stream(typ, scal, gps9)))
+		d := GPSSamples(res)
+		require.Len(t, d, 2)
+		require.False(t, d[0].Time.IsZero())
+
+		res = read(t, devc(stream(fix, dop, scal5, gps5)))
+		require.Len(t, GPSSamples(res), 1)
+	})
+}
@@ -2,6 +2,7 @@ package gpmf
 
 import (
 	"fmt"
+	"time"
 )
 
 // GPSFix represents the type of a GPS fix.
@@ -18,6 +19,20 @@ const (
 	GPS3DLock
 )
 
+// String implements fmt.Stringer.
+func (f GPSFix) String() string {
+	switch f {
+	case GPSNoLock:
+		return "No lock"
+	case GPS2DLock:
+		return "2D lock"
+	case GPS3DLock:
+		return "3D lock"
+	default:
+		return fmt.Sprintf("unknown lock: %d", uint32(f))
+	}
+}
+
 func parseGPSFix(e *Element) error {
 	v, ok := e.Data.(uint32)
 	if !ok {
@@ -26,21 +41,18 @@ func parseGPSFix(e *Element) error {
 
 	f := GPSFix(v)
 	e.Data = f
-	e.parent.Metadata[e.friendlyName()] = e.Data
+	e.parent.Metadata[e.FriendlyName()] = e.Data
+	e.parent.Metadata["gps_fix_description"] = f.String()
 
-	var s string
-	switch f {
-	case GPSNoLock:
-		s = "No lock"
-	case GPS2DLock:
-		s = "2D lock"
-	case GPS3DLock:
-		s = "3D lock"
-	default:
-		s = fmt.Sprintf("unknown lock: %d", v)
-	}
+	return nil
+}
 
-	e.parent.Metadata["gps_fix_description"] = s
+// parseGPSTime parses GPSU, the UTC time of the first GPS sample in
+// the payload, storing it as metadata for the following GPS data.
+func parseGPSTime(e *Element) error {
+	if _, ok := e.Data.(time.Time); !ok {
+		return fmt.Errorf("gps time: unexpected data type %T (expected time.Time)", e.Data)
+	}
 
-	return nil
+	return parseMetadata(e)
 }
@@ -50,6 +50,25 @@ func (v GPSDoP) Poor() bool {
 	return v > 20
 }
 
+// Rating returns the best rating which v meets, for example
+// "excellent".
+func (v GPSDoP) Rating() string {
+	switch {
+	case v.Ideal():
+		return "ideal"
+	case v.Excellent():
+		return "excellent"
+	case v.Good():
+		return "good"
+	case v.Moderate():
+		return "moderate"
+	case v.Fair():
+		return "fair"
+	default:
+		return "poor"
+	}
+}
+
 func parseGPSDoP(e *Element) error {
 	v, ok := e.Data.(uint16)
 	if !ok {
@@ -0,0 +1,32 @@
+package gpmf
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGPSDoPRating(t *testing.T) {
+	tests := []struct {
+		dop      GPSDoP
+		expected string
+	}{
+		{dop: 0.5, expected: "ideal"},
+		{dop: 1, expected: "excellent"},
+		{dop: 2, expected: "excellent"},
+		{dop: 2.01, expected: "good"},
+		{dop: 5, expected: "good"},
+		{dop: 5.01, expected: "moderate"},
+		{dop: 10, expected: "moderate"},
+		{dop: 10.01, expected: "fair"},
+		{dop: 20, expected: "fair"},
+		{dop: 20.01, expected: "poor"},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.dop), func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.dop.Rating())
+		})
+	}
+}
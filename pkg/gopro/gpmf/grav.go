@@ -0,0 +1,37 @@
+package gpmf //nolint: dupl
+
+import (
+	"time"
+)
+
+// GravityData represents gravity vector data.
+type GravityData []Gravity
+
+// offsets implements offseter.
+func (d GravityData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// Gravity represents the direction of gravity relative to the camera
+// as a unit vector.
+type Gravity struct {
+	X      float64
+	Y      float64
+	Z      float64
+	Offset time.Duration
+}
+
+func parseGravity(e *Element) error {
+	e.initMetadata()
+	return floatType[GravityData](e, 3, func(vals []float64) Gravity {
+		return Gravity{
+			X: vals[0],
+			Y: vals[1],
+			Z: vals[2],
+		}
+	})
+}
@@ -16,6 +16,7 @@ func (d GyroData) offsets(start, end time.Duration) {
 }
 
 // Gyro represents gyroscope metric for each axes.
+// Axes are the same as Accel.
 type Gyro struct {
 	X      float64
 	Y      float64
@@ -25,11 +26,11 @@ type Gyro struct {
 
 func parseGyro(e *Element) error {
 	e.initMetadata()
-	return floatType[GyroData](e, 3, func(vals []float64) Gyro {
+	return imuType[GyroData](e, func(v [3]float64) Gyro {
 		return Gyro{
-			Z: vals[0],
-			X: vals[1],
-			Y: vals[2],
+			X: v[0],
+			Y: v[1],
+			Z: v[2],
 		}
 	})
 }
@@ -89,9 +89,5 @@ func (h Header) validate() error {
 		}
 	}
 
-	if h.Type == Compressed {
-		return fmt.Errorf("header: key %q: compressed data not supported", h.FourCC())
-	}
-
 	return nil
 }
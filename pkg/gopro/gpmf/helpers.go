@@ -8,7 +8,7 @@ import (
 // validateTypeDef validates that e has a type definition contained in typeDefs and
 // returns the one it matches.
 func validateTypeDef(e *Element, typeDefs map[string]byte) (string, error) {
-	f := e.friendlyName()
+	f := e.FriendlyName()
 
 	td, ok := e.Metadata[friendlyName(KeyTypeDef)]
 	if !ok {
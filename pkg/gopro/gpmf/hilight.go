@@ -0,0 +1,131 @@
+package gpmf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// udtaHiLights is the udta box type which contains the HiLight tags.
+const udtaHiLights = "HMMT"
+
+// HiLights represents the HiLight tags of a recording, added by
+// pressing the HiLight button on the camera or app while recording.
+type HiLights struct {
+	// Tags are the offsets of the tags from the start of the recording.
+	Tags []time.Duration
+
+	// Duration is the duration of the recording, the same as that used
+	// to offset the metadata of each chapter by Decoder.DecodeChapters.
+	Duration time.Duration
+}
+
+// DecodeHiLights decodes the HiLight tags from the mp4 streams in
+// chapters, which must be the chapters of a single recording in order.
+// The chapters are treated as one continuous timeline so tag offsets
+// continue from the end of the previous chapter.
+func DecodeHiLights(chapters ...io.ReadSeeker) (*HiLights, error) {
+	hl := &HiLights{}
+	for i, rs := range chapters {
+		if err := hl.decode(rs); err != nil {
+			return nil, chapterError(err, i, len(chapters))
+		}
+	}
+
+	return hl, nil
+}
+
+// DecodeHiLightFiles decodes the HiLight tags from the named mp4 files,
+// which must be the chapters of a single recording in order, as
+// DecodeHiLights does. Only one file is open at a time.
+func DecodeHiLightFiles(names ...string) (*HiLights, error) {
+	hl := &HiLights{}
+	for i, name := range names {
+		if err := hl.decodeFile(name); err != nil {
+			return nil, chapterError(err, i, len(names))
+		}
+	}
+
+	return hl, nil
+}
+
+// decodeFile opens and decodes the HiLight tags from the mp4 file name.
+func (hl *HiLights) decodeFile(name string) error {
+	f, err := os.Open(name) //nolint: gosec // Yes it is.
+	if err != nil {
+		return fmt.Errorf("hilights: open %w", err)
+	}
+
+	defer f.Close() //nolint: errcheck
+
+	return hl.decode(f)
+}
+
+// decode adds the HiLight tags of the mp4 stream in rs offset by
+// the duration of the previous chapters.
+func (hl *HiLights) decode(rs io.ReadSeeker) error {
+	f, err := mp4.DecodeFile(rs, mp4.WithDecodeMode(mp4.DecModeLazyMdat))
+	if err != nil {
+		return fmt.Errorf("hilights: mp4 %w", err)
+	}
+
+	if f.Moov == nil {
+		return fmt.Errorf("hilights: no moov box")
+	}
+
+	for _, b := range f.Moov.Children {
+		udta, ok := b.(*mp4.UdtaBox)
+		if !ok {
+			continue
+		}
+
+		for _, c := range udta.Children {
+			if c.Type() != udtaHiLights {
+				continue
+			}
+
+			data, err := boxPayload(c)
+			if err != nil {
+				return fmt.Errorf("hilights: udta %s: %w", udtaHiLights, err)
+			}
+
+			tags, err := parseHiLights(data)
+			if err != nil {
+				return fmt.Errorf("hilights: udta %s: %w", udtaHiLights, err)
+			}
+
+			for _, v := range tags {
+				hl.Tags = append(hl.Tags, hl.Duration+v)
+			}
+		}
+	}
+
+	hl.Duration += chapterDuration(f)
+
+	return nil
+}
+
+// parseHiLights returns the tag offsets from a HMMT box payload, which
+// is a count followed by that many millisecond offsets. Cameras use a
+// fixed size box, so any data after the last tag is ignored.
+func parseHiLights(data []byte) ([]time.Duration, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("short data %d", len(data))
+	}
+
+	count := int(byteOrder.Uint32(data))
+	data = data[4:]
+	if len(data) < count*4 {
+		return nil, fmt.Errorf("count %d exceeds data length %d", count, len(data))
+	}
+
+	tags := make([]time.Duration, count)
+	for i := range tags {
+		tags[i] = time.Duration(byteOrder.Uint32(data[i*4:])) * time.Millisecond
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,147 @@
+package gpmf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHiLights(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected []time.Duration
+		err      bool
+	}{
+		{
+			name:     "tags",
+			data:     be(t, uint32(2), uint32(1500), uint32(62000)),
+			expected: []time.Duration{1500 * time.Millisecond, 62 * time.Second},
+		},
+		{
+			name:     "padded",
+			data:     be(t, uint32(1), uint32(1500), uint32(0), uint32(0)),
+			expected: []time.Duration{1500 * time.Millisecond},
+		},
+		{
+			name:     "empty",
+			data:     be(t, uint32(0)),
+			expected: []time.Duration{},
+		},
+		{name: "short", data: []byte{0, 1}, err: true},
+		{name: "count", data: be(t, uint32(2), uint32(1500)), err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tags, err := parseHiLights(tc.data)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, tags)
+		})
+	}
+}
+
+func TestDecodeHiLights(t *testing.T) {
+	payloads := testPayloads(t, "hero6-multi-chunk")
+	chapter := func(ms ...uint32) []byte {
+		udta := &mp4.UdtaBox{}
+		udta.AddChild(testBox(t, udtaHiLights, be(t, append([]uint32{uint32(len(ms))}, ms...)))) //nolint: gosec
+		f := testMP4(t, payloads, 1001, udta)
+
+		// Set a movie duration, which CreateMvhd leaves as zero, which
+		// differs from that of the metadata track to check it isn't used.
+		dec, err := mp4.DecodeFile(bytes.NewReader(f))
+		require.NoError(t, err)
+		dec.Moov.Mvhd.Timescale = 1000
+		dec.Moov.Mvhd.Duration = 60000
+
+		var buf bytes.Buffer
+		require.NoError(t, dec.Encode(&buf))
+		return buf.Bytes()
+	}
+
+	chapters := [][]byte{
+		chapter(1500, 10000),
+		chapter(),
+		chapter(250),
+	}
+	// Chapter durations are the same as used for metadata offsets.
+	chapterDur := time.Duration(len(payloads)) * 1001 * time.Millisecond
+	expected := &HiLights{
+		Tags: []time.Duration{
+			1500 * time.Millisecond,
+			10 * time.Second,
+			2*chapterDur + 250*time.Millisecond,
+		},
+		Duration: 3 * chapterDur,
+	}
+
+	t.Run("readers", func(t *testing.T) {
+		hl, err := DecodeHiLights(
+			bytes.NewReader(chapters[0]),
+			bytes.NewReader(chapters[1]),
+			bytes.NewReader(chapters[2]),
+		)
+		require.NoError(t, err)
+		require.Equal(t, expected, hl)
+	})
+
+	t.Run("files", func(t *testing.T) {
+		dir := t.TempDir()
+		names := make([]string, len(chapters))
+		for i, c := range chapters {
+			names[i] = filepath.Join(dir, fmt.Sprintf("GX%02d0001.MP4", i+1))
+			require.NoError(t, os.WriteFile(names[i], c, 0o600))
+		}
+
+		hl, err := DecodeHiLightFiles(names...)
+		require.NoError(t, err)
+		require.Equal(t, expected, hl)
+
+		_, err = DecodeHiLightFiles(names[0], filepath.Join(dir, "missing.mp4"))
+		require.ErrorContains(t, err, "chapter 2")
+	})
+
+	t.Run("none", func(t *testing.T) {
+		hl, err := DecodeHiLights(bytes.NewReader(testMP4(t, payloads, 1001)))
+		require.NoError(t, err)
+		require.Empty(t, hl.Tags)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := DecodeHiLights(io.NewSectionReader(bytes.NewReader(nil), 0, 0))
+		require.Error(t, err)
+	})
+}
+
+func TestGPSDataTimeAt(t *testing.T) {
+	utc := time.Date(2022, time.May, 31, 8, 1, 33, 0, time.UTC)
+	data := GPSData{
+		{Offset: 0},
+		{Offset: time.Second, Time: utc},
+		{Offset: 2 * time.Second, Time: utc.Add(time.Second)},
+	}
+
+	v, ok := data.TimeAt(2500 * time.Millisecond)
+	require.True(t, ok)
+	require.Equal(t, utc.Add(1500*time.Millisecond), v)
+
+	v, ok = data.TimeAt(0)
+	require.True(t, ok)
+	require.Equal(t, utc.Add(-time.Second), v)
+
+	_, ok = data[:1].TimeAt(0)
+	require.False(t, ok)
+}
@@ -0,0 +1,427 @@
+package gpmf
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ShutterData represents shutter exposure time data.
+type ShutterData []Shutter
+
+// offsets implements offseter.
+func (d ShutterData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// Shutter represents the shutter exposure time of a frame.
+type Shutter struct {
+	// Exposure is the exposure time in seconds.
+	Exposure float64
+	Offset   time.Duration
+}
+
+// Duration returns the exposure time as a time.Duration.
+func (s Shutter) Duration() time.Duration {
+	return seconds(s.Exposure)
+}
+
+func parseShutter(e *Element) error {
+	e.initMetadata()
+	return floatType[ShutterData](e, 1, func(vals []float64) Shutter {
+		return Shutter{Exposure: vals[0]}
+	})
+}
+
+// ISOData represents sensor ISO data.
+type ISOData []ISO
+
+// offsets implements offseter.
+func (d ISOData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// ISO represents the sensor ISO of a frame.
+type ISO struct {
+	Value  float64
+	Offset time.Duration
+}
+
+func parseISO(e *Element) error {
+	e.initMetadata()
+	return floatType[ISOData](e, 1, func(vals []float64) ISO {
+		return ISO{Value: vals[0]}
+	})
+}
+
+// ExposureData represents exposure data.
+type ExposureData []Exposure
+
+// Exposure represents the exposure of a frame.
+type Exposure struct {
+	// Shutter is the exposure time in seconds.
+	Shutter float64
+
+	// ISO is the sensor ISO.
+	ISO    float64
+	Offset time.Duration
+}
+
+// EV returns the exposure value at ISO 100 for an f/2.8 lens, which
+// is constant for GoPro cameras, so larger values are brighter scenes.
+// It returns NaN if the shutter or ISO is unknown.
+func (e Exposure) EV() float64 {
+	if e.Shutter <= 0 || e.ISO <= 0 {
+		return math.NaN()
+	}
+
+	const aperture = 2.8
+
+	return math.Log2(aperture*aperture/e.Shutter) - math.Log2(e.ISO/100)
+}
+
+// Exposures returns the exposures from shutter and the ISO with the
+// closest offset from iso, which are typically recorded per frame.
+func Exposures(shutter ShutterData, iso ISOData) ExposureData {
+	d := make(ExposureData, len(shutter))
+	var j int
+	for i, s := range shutter {
+		d[i] = Exposure{Shutter: s.Exposure, Offset: s.Offset}
+		if len(iso) == 0 {
+			continue
+		}
+
+		for j+1 < len(iso) && absDuration(iso[j+1].Offset-s.Offset) <= absDuration(iso[j].Offset-s.Offset) {
+			j++
+		}
+		d[i].ISO = iso[j].Value
+	}
+
+	return d
+}
+
+// absDuration returns the absolute value of d.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
+
+// WhiteBalanceData represents white balance data.
+type WhiteBalanceData []WhiteBalance
+
+// offsets implements offseter.
+func (d WhiteBalanceData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// WhiteBalance represents the white balance of a frame.
+type WhiteBalance struct {
+	// Kelvin is the colour temperature in kelvin.
+	Kelvin float64
+	Offset time.Duration
+}
+
+func parseWhiteBalance(e *Element) error {
+	e.initMetadata()
+	return floatType[WhiteBalanceData](e, 1, func(vals []float64) WhiteBalance {
+		return WhiteBalance{Kelvin: vals[0]}
+	})
+}
+
+// LumaData represents average frame luma data.
+type LumaData []Luma
+
+// offsets implements offseter.
+func (d LumaData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// Luma represents the average luma (Y) of a frame.
+type Luma struct {
+	// Value is the average luma from 0 (black) to 255 (white).
+	Value  float64
+	Offset time.Duration
+}
+
+func parseLuma(e *Element) error {
+	e.initMetadata()
+	return floatType[LumaData](e, 1, func(vals []float64) Luma {
+		return Luma{Value: vals[0]}
+	})
+}
+
+// UniformityData represents image uniformity data.
+type UniformityData []Uniformity
+
+// offsets implements offseter.
+func (d UniformityData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// Uniformity represents the uniformity of a frame.
+type Uniformity struct {
+	// Value is the uniformity from 0 to 1, where 1 is a solid colour.
+	Value  float64
+	Offset time.Duration
+}
+
+func parseUniformity(e *Element) error {
+	e.initMetadata()
+	return floatType[UniformityData](e, 1, func(vals []float64) Uniformity {
+		return Uniformity{Value: vals[0]}
+	})
+}
+
+// HueData represents predominant frame hue data.
+type HueData []Hues
+
+// offsets implements offseter.
+func (d HueData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// Hues represents the predominant hues of a frame.
+type Hues struct {
+	Hues   []Hue
+	Offset time.Duration
+}
+
+// Hue represents a single hue and its weight.
+type Hue struct {
+	// Hue is the HSV hue in degrees.
+	Hue float64
+
+	// Weight is the weight of the hue in the frame from 0 to 1.
+	Weight float64
+}
+
+// parseHues parses HUES which are recorded as hue and weight byte
+// pairs, each structure representing a frame if there is a type
+// definition otherwise the element is treated as a single frame.
+func parseHues(e *Element) error {
+	e.initMetadata()
+	groups, err := pairGroups(e)
+	if err != nil || groups == nil {
+		return err
+	}
+
+	d := make(HueData, len(groups))
+	for i, g := range groups {
+		h := make([]Hue, len(g)/2)
+		for j := range h {
+			h[j] = Hue{
+				Hue:    toFloat(g[j*2]) * 360 / 255,
+				Weight: toFloat(g[j*2+1]) / 255,
+			}
+		}
+		d[i].Hues = h
+	}
+
+	e.Data = d
+
+	return nil
+}
+
+// Scene classes as used by SCEN.
+const (
+	SceneSnow       = "SNOW"
+	SceneUrban      = "URBA"
+	SceneIndoor     = "INDO"
+	SceneWater      = "WATR"
+	SceneVegetation = "VEGE"
+	SceneBeach      = "BEAC"
+)
+
+// SceneData represents scene classification data.
+type SceneData []Scene
+
+// offsets implements offseter.
+func (d SceneData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// Scene represents the scene classification of a frame.
+type Scene struct {
+	Classes []SceneClass
+	Offset  time.Duration
+}
+
+// Probability returns the probability of class, 0 if not present.
+func (s Scene) Probability(class string) float64 {
+	for _, c := range s.Classes {
+		if c.Class == class {
+			return c.Probability
+		}
+	}
+
+	return 0
+}
+
+// Best returns the class with the highest probability.
+func (s Scene) Best() SceneClass {
+	var best SceneClass
+	for _, c := range s.Classes {
+		if c.Probability > best.Probability {
+			best = c
+		}
+	}
+
+	return best
+}
+
+// SceneClass represents the probability of a single scene class.
+type SceneClass struct {
+	// Class is the FourCC of the class e.g. SceneUrban.
+	Class string
+
+	// Probability is the probability from 0 to 1.
+	Probability float64
+}
+
+// parseScene parses SCEN which are recorded as class FourCC and
+// probability pairs. A new frame is started when a class repeats, so
+// both one structure per class and one structure per frame are supported.
+func parseScene(e *Element) error {
+	e.initMetadata()
+	d, ok := e.Data.(StructData)
+	if !ok {
+		if e.Data == nil {
+			// No type definition so nothing to decode.
+			return nil
+		}
+		return fmt.Errorf("%s: unexpected data type %T (expected StructData)", e.FriendlyName(), e.Data)
+	}
+
+	var res SceneData
+	var cur Scene
+	seen := make(map[string]bool)
+	for _, s := range d {
+		vals := flatten(s.Fields)
+		if len(vals)%2 != 0 {
+			return fmt.Errorf("%s: unexpected number of fields %d (not a multiple of 2)", e.FriendlyName(), len(vals))
+		}
+
+		for i := 0; i < len(vals); i += 2 {
+			class, ok := vals[i].(string)
+			if !ok {
+				return fmt.Errorf("%s: unexpected class type %T (expected string)", e.FriendlyName(), vals[i])
+			}
+
+			if seen[class] {
+				res = append(res, cur)
+				cur = Scene{}
+				seen = make(map[string]bool)
+			}
+			seen[class] = true
+			cur.Classes = append(cur.Classes, SceneClass{Class: class, Probability: toFloat(vals[i+1])})
+		}
+	}
+
+	if len(cur.Classes) != 0 {
+		res = append(res, cur)
+	}
+
+	e.Data = res
+
+	return nil
+}
+
+// pairGroups returns the values of e grouped by structure if
+// it's StructData, otherwise a single group of all values.
+// It returns nil if e has no data.
+func pairGroups(e *Element) ([][]any, error) {
+	var groups [][]any
+	switch v := e.Data.(type) {
+	case nil:
+		return nil, nil
+	case StructData:
+		for _, s := range v {
+			groups = append(groups, flatten(s.Fields))
+		}
+	default:
+		vals, err := floatSlice(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.FriendlyName(), err)
+		}
+		g := make([]any, len(vals))
+		for i, v := range vals {
+			g[i] = v
+		}
+		groups = [][]any{g}
+	}
+
+	for _, g := range groups {
+		if len(g)%2 != 0 {
+			return nil, fmt.Errorf("%s: unexpected number of values %d (not a multiple of 2)", e.FriendlyName(), len(g))
+		}
+	}
+
+	return groups, nil
+}
+
+// flatten returns fields with any slices expanded.
+func flatten(fields []any) []any {
+	var res []any
+	for _, f := range fields {
+		switch v := f.(type) {
+		case []string:
+			for _, s := range v {
+				res = append(res, s)
+			}
+		case string:
+			res = append(res, v)
+		default:
+			vals, err := floatSlice(v)
+			if err != nil {
+				res = append(res, v)
+				continue
+			}
+			for _, fv := range vals {
+				res = append(res, fv)
+			}
+		}
+	}
+
+	return res
+}
+
+// toFloat returns v as a float64, 0 if it isn't a number.
+func toFloat(v any) float64 {
+	vals, err := floatSlice(v)
+	if err != nil || len(vals) != 1 {
+		return 0
+	}
+
+	return vals[0]
+}
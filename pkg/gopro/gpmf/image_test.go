@@ -0,0 +1,120 @@
+package gpmf
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageStreams(t *testing.T) {
+	read := func(t *testing.T, elems ...[]byte) *Element {
+		t.Helper()
+		b := bytes.Join(elems, nil)
+		strm := klv(KeyStream, Nested, 1, uint16(len(b)), b) //nolint: gosec
+		res, err := NewReader().Read(bytes.NewReader(strm))
+		require.NoError(t, err)
+		require.NoError(t, Walk(res, newOffsetWalker(0, 1000, time.Millisecond, nil).walk))
+		return res[0].Nested[len(elems)-1]
+	}
+
+	typeDef := func(def string) []byte {
+		return klv(KeyTypeDef, String, 1, uint16(len(def)), []byte(def)) //nolint: gosec
+	}
+
+	t.Run("exposure", func(t *testing.T) {
+		shut := read(t, klv(KeyShutter, Float32, 4, 2, be(t, float32(0.01), float32(0.005)))).Data
+		require.IsType(t, ShutterData{}, shut)
+		iso := read(t, klv(KeySensorISO, Uint16, 2, 2, be(t, uint16(100), uint16(400)))).Data
+		require.Equal(t, ISOData{{Value: 100}, {Value: 400, Offset: 500 * time.Millisecond}}, iso)
+
+		s, ok := shut.(ShutterData)
+		require.True(t, ok)
+		require.Equal(t, 5*time.Millisecond, s[1].Duration())
+
+		exp := Exposures(s, iso.(ISOData)) //nolint: forcetypeassert
+		require.Len(t, exp, 2)
+		require.Equal(t, 400.0, exp[1].ISO)
+		require.InDelta(t, 0.005, exp[1].Shutter, 1e-9)
+		require.InDelta(t, math.Log2(2.8*2.8/0.01), exp[0].EV(), 1e-6)
+		require.InDelta(t, exp[0].EV()-1, exp[1].EV(), 1e-6)
+		require.True(t, math.IsNaN(Exposure{}.EV()))
+	})
+
+	t.Run("white-balance", func(t *testing.T) {
+		require.Equal(t, WhiteBalanceData{{Kelvin: 5500}},
+			read(t, klv(KeyWhiteBalance, Uint16, 2, 1, be(t, uint16(5500)))).Data)
+	})
+
+	t.Run("luma", func(t *testing.T) {
+		require.Equal(t, LumaData{{Value: 12}, {Value: 240, Offset: 500 * time.Millisecond}},
+			read(t, klv(KeyFrameLuma, Uint8, 1, 2, []byte{12, 240})).Data)
+	})
+
+	t.Run("uniformity", func(t *testing.T) {
+		require.Equal(t, UniformityData{{Value: 0.25}},
+			read(t, klv(KeyImageUniformity, Float32, 4, 1, be(t, float32(0.25)))).Data)
+	})
+
+	t.Run("hues", func(t *testing.T) {
+		require.Equal(t, HueData{
+			{Hues: []Hue{{Hue: 0, Weight: 1}, {Hue: 120, Weight: 0.2}}},
+			{Hues: []Hue{{Hue: 360, Weight: 0}, {Hue: 240, Weight: 0.6}}, Offset: 500 * time.Millisecond},
+		}, read(t, typeDef("BBBB"), klv(KeyFrameHues, Complex, 4, 2, []byte{0, 255, 85, 51, 255, 0, 170, 153})).Data)
+	})
+
+	t.Run("scene", func(t *testing.T) {
+		raw := func(pairs ...any) []byte {
+			var b []byte
+			for i := 0; i < len(pairs); i += 2 {
+				b = append(b, []byte(pairs[i].(string))...) //nolint: forcetypeassert
+				b = append(b, be(t, pairs[i+1])...)
+			}
+			return b
+		}
+		pairs := []any{
+			SceneUrban, float32(0.75), SceneVegetation, float32(0.25),
+			SceneUrban, float32(0.5), SceneVegetation, float32(0.5),
+		}
+		expected := SceneData{
+			{Classes: []SceneClass{{SceneUrban, 0.75}, {SceneVegetation, 0.25}}},
+			{Classes: []SceneClass{{SceneUrban, 0.5}, {SceneVegetation, 0.5}}, Offset: 500 * time.Millisecond},
+		}
+
+		// One structure per class.
+		require.Equal(t, expected, read(t, typeDef("Ff"), klv(KeySceneClassifier, Complex, 8, 4, raw(pairs...))).Data)
+
+		// One structure per frame.
+		d := read(t, typeDef("FfFf"), klv(KeySceneClassifier, Complex, 16, 2, raw(pairs...))).Data
+		require.Equal(t, expected, d)
+
+		s := d.(SceneData)[0] //nolint: forcetypeassert
+		require.Equal(t, SceneClass{SceneUrban, 0.75}, s.Best())
+		require.InDelta(t, 0.25, s.Probability(SceneVegetation), 1e-9)
+		require.Zero(t, s.Probability(SceneSnow))
+
+		// Opaque without a type definition.
+		require.Nil(t, read(t, klv(KeySceneClassifier, Complex, 8, 4, raw(pairs...))).Data)
+	})
+
+	t.Run("hero6", func(t *testing.T) {
+		f, err := os.Open("../../../test/hero6.raw")
+		require.NoError(t, err)
+		defer f.Close() //nolint: errcheck
+
+		data, err := NewReader().Read(f)
+		require.NoError(t, err)
+
+		types := make(map[string]any)
+		require.NoError(t, Walk(data, func(e *Element) error {
+			types[e.Header.FourCC()] = e.Data
+			return nil
+		}))
+		require.IsType(t, ShutterData{}, types[KeyShutter])
+		require.IsType(t, ISOData{}, types[KeySensorISO])
+		require.IsType(t, WhiteBalanceData{}, types[KeyWhiteBalance])
+	})
+}
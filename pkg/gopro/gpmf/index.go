@@ -0,0 +1,249 @@
+package gpmf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Sample represents a single time stamped sample of a stream.
+type Sample struct {
+	// Offset is the time offset of the sample.
+	Offset time.Duration
+
+	// Value is the sample, for example a GPS for GPS5 data.
+	Value any
+
+	// Metadata is the stream metadata of the payload containing the
+	// sample, for example the GPS fix and dilution of precision.
+	Metadata map[string]any
+}
+
+// Series represents the samples of a single stream in offset order.
+type Series struct {
+	// Device is the name of the device which recorded the stream.
+	Device string
+
+	// Key is the FourCC of the streams data element, for example GPS5.
+	Key string
+
+	// Name is the stream name.
+	Name string
+
+	// StandardUnits are the SI units, if any.
+	StandardUnits []string
+
+	// DisplayUnits are the display units, if any.
+	DisplayUnits []string
+
+	// Samples are the samples of the stream sorted by offset.
+	Samples []Sample
+}
+
+// Between returns the samples of s with offsets between from and
+// to inclusive.
+func (s *Series) Between(from, to time.Duration) []Sample {
+	i := sort.Search(len(s.Samples), func(i int) bool {
+		return s.Samples[i].Offset >= from
+	})
+	j := sort.Search(len(s.Samples), func(i int) bool {
+		return s.Samples[i].Offset > to
+	})
+	if i >= j {
+		return nil
+	}
+
+	return s.Samples[i:j]
+}
+
+// Telemetry is a time indexed view of decoded metadata which
+// provides access to the samples of each stream by time.
+type Telemetry struct {
+	series []*Series
+	index  map[string]*Series
+	end    time.Duration
+}
+
+// Index returns the Telemetry of elems, which are typically the
+// elements of all the payloads of a recording as returned by
+// Decoder.Decode. All the samples are held in memory, so to process
+// long recordings in constant memory use Decoder.DecodePayloads.
+//
+// Samples without their own offset, for example plain numeric data
+// such as ISOG, are evenly spaced across the time span of the other
+// samples recorded by the same device in the same payload.
+func Index(elems []*Element) *Telemetry {
+	t := &Telemetry{index: make(map[string]*Series)}
+	for _, e := range elems {
+		if e.Header.FourCC() == KeyDevice {
+			t.addDevice(e)
+		}
+	}
+
+	for _, s := range t.series {
+		sort.SliceStable(s.Samples, func(i, j int) bool {
+			return s.Samples[i].Offset < s.Samples[j].Offset
+		})
+		if n := len(s.Samples); n > 0 {
+			t.end = max(t.end, s.Samples[n-1].Offset)
+		}
+	}
+
+	return t
+}
+
+// addDevice adds the streams of the device element dev.
+func (t *Telemetry) addDevice(dev *Element) {
+	name, _ := dev.Metadata[friendlyName(KeyDeviceName)].(string)
+	id := dev.Metadata[friendlyName(KeyDeviceID)]
+
+	// Determine the span of the payload from the streams with offsets.
+	var untimed []*Element
+	var start, end time.Duration
+	var timed bool
+	for _, strm := range dev.Nested {
+		data := streamData(strm)
+		if data == nil {
+			continue
+		}
+
+		offs := sampleOffsets(data.Data)
+		if offs == nil {
+			untimed = append(untimed, strm)
+			continue
+		}
+
+		for _, v := range offs {
+			if !timed || v < start {
+				start = v
+			}
+			end = max(end, v)
+			timed = true
+		}
+
+		t.add(id, name, strm, data, offs)
+	}
+
+	for _, strm := range untimed {
+		data := streamData(strm)
+		n := dataLen(data.Data)
+		offs := make([]time.Duration, n)
+		if n > 0 {
+			offsets(start, end, offs, func(i int, val time.Duration) {
+				offs[i] = val
+			})
+		}
+		t.add(id, name, strm, data, offs)
+	}
+}
+
+// add adds the samples of the data element of strm with offsets offs.
+func (t *Telemetry) add(id any, device string, strm, data *Element, offs []time.Duration) {
+	key := data.Header.FourCC()
+	k := fmt.Sprintf("%v/%s/%s", id, device, key)
+	s, ok := t.index[k]
+	if !ok {
+		s = &Series{
+			Device:        device,
+			Key:           key,
+			StandardUnits: units(strm.Metadata[friendlyName(KeyStandardUnits)]),
+			DisplayUnits:  units(strm.Metadata[friendlyName(KeyDisplayUnits)]),
+		}
+		s.Name, _ = strm.Metadata[friendlyName(KeyStreamName)].(string)
+		t.index[k] = s
+		t.series = append(t.series, s)
+	}
+
+	v := reflect.ValueOf(data.Data)
+	if v.Kind() != reflect.Slice {
+		s.Samples = append(s.Samples, Sample{Value: data.Data, Metadata: strm.Metadata})
+		return
+	}
+
+	for i := range v.Len() {
+		s.Samples = append(s.Samples, Sample{
+			Offset:   offs[i],
+			Value:    v.Index(i).Interface(),
+			Metadata: strm.Metadata,
+		})
+	}
+}
+
+// streamData returns the data element of the stream element strm,
+// nil if it isn't a stream or has no data.
+func streamData(strm *Element) *Element {
+	if strm.Header.FourCC() != KeyStream || len(strm.Nested) == 0 {
+		return nil
+	}
+
+	// The data is always the last element of a stream, unless it
+	// has none in which case the last element is sticky metadata.
+	data := strm.Nested[len(strm.Nested)-1]
+	if _, ok := strm.Metadata[data.FriendlyName()]; ok {
+		return nil
+	}
+
+	return data
+}
+
+// Series returns all the series in the order first seen.
+func (t *Telemetry) Series() []*Series {
+	return t.series
+}
+
+// Stream returns the first series with the key, nil if not found.
+func (t *Telemetry) Stream(key string) *Series {
+	for _, s := range t.series {
+		if s.Key == key {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// End returns the offset of the last sample.
+func (t *Telemetry) End() time.Duration {
+	return t.end
+}
+
+// GPS returns the GPS samples between from and to inclusive.
+// If both GPS9 and GPS5 are present only the more detailed GPS9
+// samples are returned.
+func (t *Telemetry) GPS(from, to time.Duration) GPSData {
+	key := KeyGPS
+	if t.Stream(KeyGPS9) != nil {
+		key = KeyGPS9
+	}
+
+	return Values[GPS](t.Stream(key), from, to)
+}
+
+// Accel returns the accelerometer samples between from and to inclusive.
+func (t *Telemetry) Accel(from, to time.Duration) AccelData {
+	return Values[Accel](t.Stream(KeyAccel), from, to)
+}
+
+// Gyro returns the gyroscope samples between from and to inclusive.
+func (t *Telemetry) Gyro(from, to time.Duration) GyroData {
+	return Values[Gyro](t.Stream(KeyGyro), from, to)
+}
+
+// Values returns the values of type T of the samples of s between
+// from and to inclusive, skipping any of a different type.
+func Values[T any](s *Series, from, to time.Duration) []T {
+	if s == nil {
+		return nil
+	}
+
+	samples := s.Between(from, to)
+	vals := make([]T, 0, len(samples))
+	for _, v := range samples {
+		if val, ok := v.Value.(T); ok {
+			vals = append(vals, val)
+		}
+	}
+
+	return vals
+}
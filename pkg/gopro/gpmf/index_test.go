@@ -0,0 +1,75 @@
+package gpmf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	var elems []*Element
+	testRaw(t, "hero6-multi-chunk", func(p *Payload) error {
+		elems = append(elems, p.Elements...)
+		return nil
+	})
+
+	tel := Index(elems)
+	require.NotEmpty(t, tel.Series())
+	for _, s := range tel.Series() {
+		for i := 1; i < len(s.Samples); i++ {
+			require.LessOrEqual(t, s.Samples[i-1].Offset, s.Samples[i].Offset, "%s sample %d", s.Key, i)
+		}
+	}
+
+	gps := tel.GPS(0, tel.End())
+	require.Equal(t, GPSSamples(elems), gps)
+	for i := 1; i < len(gps); i++ {
+		require.Greater(t, gps[i].Offset, gps[i-1].Offset, "gps sample %d", i)
+	}
+
+	strm := tel.Stream(KeyGPS)
+	require.NotNil(t, strm)
+	require.Equal(t, "Camera", strm.Device)
+	require.Equal(t, []string{"deg", "deg", "m", "m/s", "m/s"}, strm.DisplayUnits)
+	require.Contains(t, strm.Samples[0].Metadata, friendlyName(KeyGPSFix))
+
+	t.Run("between", func(t *testing.T) {
+		from, to := 5*time.Second, 6*time.Second
+		got := tel.GPS(from, to)
+		require.NotEmpty(t, got)
+		require.Less(t, len(got), len(gps))
+		for _, v := range got {
+			require.GreaterOrEqual(t, v.Offset, from)
+			require.LessOrEqual(t, v.Offset, to)
+		}
+
+		accel := tel.Accel(from, to)
+		require.InDelta(t, 200, len(accel), 5)
+		require.Empty(t, tel.GPS(to, from))
+	})
+
+	t.Run("untimed", func(t *testing.T) {
+		isog := tel.Stream(KeyImageSensorGain)
+		require.NotNil(t, isog)
+		var want int
+		Walk(elems, func(e *Element) error { //nolint: errcheck // Never returns an error.
+			if e.Header.FourCC() == KeyImageSensorGain {
+				want += dataLen(e.Data)
+			}
+			return nil
+		})
+		require.NotZero(t, want)
+		require.Len(t, isog.Samples, want)
+		require.Equal(t, time.Duration(0), isog.Samples[0].Offset)
+		require.Greater(t, isog.Samples[len(isog.Samples)-1].Offset, 20*time.Second)
+		require.InDelta(t, 24, len(Values[float32](isog, 0, time.Second)), 1)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		require.Nil(t, tel.Stream("XXXX"))
+		require.Empty(t, Values[Gyro](tel.Stream("XXXX"), 0, time.Second))
+		require.Empty(t, Values[Gyro](tel.Stream(KeyAccel), 0, time.Second))
+		require.Nil(t, Values[float64](nil, 0, time.Second))
+	})
+}
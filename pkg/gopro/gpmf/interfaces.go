@@ -18,3 +18,10 @@ type number interface {
 		int64 | uint64 |
 		Int16_16 | Int32_32
 }
+
+// Offsetter is implemented by custom Data types which need the time
+// offsets of their samples set by the Decoder. Offsets are calculated
+// the same way as they are for known data types, see SetOffsets.
+type Offsetter interface {
+	SetOffsets(start, end time.Duration)
+}
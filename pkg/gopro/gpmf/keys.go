@@ -1,5 +1,10 @@
 package gpmf
 
+import (
+	"fmt"
+	"sync"
+)
+
 // Known keys.
 // Additional information can be found here:
 // https://exiftool.org/TagNames/GoPro.html
@@ -53,6 +58,9 @@ var (
 	// KeyGPS GPS location.
 	KeyGPS = "GPS5"
 
+	// KeyGPS9 GPS location with per sample time, DOP and fix (HERO11 and later).
+	KeyGPS9 = "GPS9"
+
 	// KeyImageSensorGain Image sensor gain.
 	KeyImageSensorGain = "ISOG"
 
@@ -164,19 +172,77 @@ var (
 	// KeyPreformatted - GPMF data.
 	KeyPreformatted = "PFRM"
 
+	// KeyFirmware camera firmware version (udta).
+	KeyFirmware = "FMWR"
+
+	// KeyCameraSerial camera serial number (udta).
+	KeyCameraSerial = "CASN"
+
+	// KeyModel camera model name (udta).
+	KeyModel = "MINF"
+
+	// KeyMediaUID media unique ID (udta).
+	KeyMediaUID = "MUID"
+
+	// KeyFieldOfView field of view setting e.g. W, S, N, L (udta).
+	KeyFieldOfView = "VFOV"
+
+	// KeyDiagonalFieldOfView diagonal field of view in degrees (udta).
+	KeyDiagonalFieldOfView = "ZFOV"
+
+	// KeyStabilization electronic image stabilisation Y or N (udta).
+	KeyStabilization = "EISE"
+
+	// KeyProTune ProTune Y or N (udta).
+	KeyProTune = "PRTN"
+
 	// KeyTimeStamps stream of all the timestamps delivered.
 	// Generally don't use this. This would be if your sensor has no periodic times,
 	// yet precision is required, or for debugging.
 	KeyTimeStamps = "STPS"
 )
 
-type parserFunc func(*Element) error
+// structuralKeys are the keys which the decoding of other elements
+// depends on, so can't be registered.
+var structuralKeys = map[string]struct{}{
+	KeyDevice:          {},
+	KeyDeviceID:        {},
+	KeyDeviceName:      {},
+	KeyStream:          {},
+	KeyStreamName:      {},
+	KeyScale:           {},
+	KeyStandardUnits:   {},
+	KeyDisplayUnits:    {},
+	KeyTypeDef:         {},
+	KeyTimeOffset:      {},
+	KeyEmpty:           {},
+	KeyGPSTime:         {},
+	KeyGPSFix:          {},
+	KeyGSPDoP:          {},
+	KeyTimeStamp:       {},
+	KeyBeginTimingData: {},
+	KeyEndTimingData:   {},
+	KeyTotalSamples:    {},
+	KeyQuantize:        {},
+	KeyOrientationIn:   {},
+	KeyOrientationOut:  {},
+	KeyMatrix:          {},
+}
+
+// ParserFunc is the type of the function called to parse an Element
+// after its Data has been decoded and scaled. It typically converts
+// Data into a more useful type or stores it as metadata.
+type ParserFunc func(e *Element) error
 
 // We need two hashes to avoid an initialisation loop.
 var (
+	// keysMu protects keyParsers and keyNames from concurrent
+	// registration.
+	keysMu sync.RWMutex
+
 	// keyParsers has nil entries so we can check if know about
 	// a given key.
-	keyParsers = map[string]parserFunc{
+	keyParsers = map[string]ParserFunc{
 		KeyDevice:               nil,
 		KeyDeviceID:             parseMetadata,
 		KeyDeviceName:           parseMetadata,
@@ -189,47 +255,56 @@ var (
 		KeyTypeDef:              parseMetadata,
 		KeyTimeOffset:           nil,
 		KeyEmpty:                nil,
-		KeyShutter:              nil,
+		KeyShutter:              parseShutter,
 		KeyAccel:                parseAccel,
 		KeyGyro:                 parseGyro,
 		KeyGPS:                  parseGPS,
+		KeyGPS9:                 parseGPS9,
 		KeyImageSensorGain:      nil,
-		KeyGPSTime:              parseMetadata,
+		KeyGPSTime:              parseGPSTime,
 		KeyGPSFix:               parseGPSFix,
 		KeyGSPDoP:               parseGPSDoP,
-		KeyTimeStamp:            nil,
+		KeyTimeStamp:            parseMetadata,
 		KeyMagnetometer:         parseMagnetometer,
 		KeyFace:                 parseFace,
 		KeyFaces:                parseHasMetadata,
-		KeySensorISO:            parseHasMetadata,
+		KeySensorISO:            parseISO,
 		KeyAutoLowLight:         nil,
-		KeyWhiteBalance:         nil,
+		KeyWhiteBalance:         parseWhiteBalance,
 		KeyWhiteBalanceRGB:      parseWhiteBalanceRGB,
-		KeyFrameLuma:            nil,
-		KeyFrameHues:            nil,
-		KeyImageUniformity:      nil,
-		KeySceneClassifier:      nil,
+		KeyFrameLuma:            parseLuma,
+		KeyFrameHues:            parseHues,
+		KeyImageUniformity:      parseUniformity,
+		KeySceneClassifier:      parseScene,
 		KeySensorReadOut:        nil,
-		KeyCameraOrientation:    nil,
-		KeyImageOrientation:     nil,
-		KeyGavityVector:         nil,
+		KeyCameraOrientation:    parseOrientation,
+		KeyImageOrientation:     parseOrientation,
+		KeyGavityVector:         parseGravity,
 		KeyWindProcessing:       nil,
 		KeyMicrophoneWet:        nil,
 		KeyDisparityTrack:       nil,
 		KeyMainVideoFrameSkip:   nil,
 		KeyLowResVideoFrameSkip: nil,
-		KeyBeginTimingData:      nil,
-		KeyEndTimingData:        nil,
+		KeyBeginTimingData:      parseMetadata,
+		KeyEndTimingData:        parseMetadata,
 		KeyTotalSamples:         parseMetadata,
 		KeyDeviceTemperature:    parseMetadata,
-		KeyQuantize:             nil,
+		KeyQuantize:             parseMetadata,
 		KeyVersion:              nil,
 		KeyFree:                 nil,
-		KeyOrientationIn:        nil,
-		KeyOrientationOut:       nil,
-		KeyMatrix:               nil,
+		KeyOrientationIn:        parseMetadata,
+		KeyOrientationOut:       parseMetadata,
+		KeyMatrix:               parseMetadata,
 		KeyPreformatted:         nil,
 		KeyTimeStamps:           nil,
+		KeyFirmware:             nil,
+		KeyCameraSerial:         nil,
+		KeyModel:                nil,
+		KeyMediaUID:             nil,
+		KeyFieldOfView:          nil,
+		KeyDiagonalFieldOfView:  nil,
+		KeyStabilization:        nil,
+		KeyProTune:              nil,
 	}
 
 	keyNames = map[string]string{
@@ -243,19 +318,75 @@ var (
 		KeyAccel:             "acceleration",
 		KeyGyro:              "gyroscope",
 		KeyGPS:               "gps",
+		KeyGPS9:              "gps9",
 		KeyGPSTime:           "gps_time",
 		KeyGPSFix:            "gps_fix",
 		KeyGSPDoP:            "gps_dilution_of_precision",
 		KeyMagnetometer:      "magnetometer",
 		KeyFace:              "face_detection",
 		KeyFaces:             "faces",
+		KeyShutter:           "shutter",
+		KeySensorISO:         "sensor_iso",
+		KeyWhiteBalance:      "white_balance",
 		KeyWhiteBalanceRGB:   "white_balance_rgb",
+		KeyFrameLuma:         "frame_luma",
+		KeyFrameHues:         "frame_hues",
+		KeyImageUniformity:   "image_uniformity",
+		KeySceneClassifier:   "scene_classifier",
+		KeyCameraOrientation: "camera_orientation",
+		KeyImageOrientation:  "image_orientation",
+		KeyGavityVector:      "gravity_vector",
+		KeyTimeStamp:         "timestamp",
+		KeyBeginTimingData:   "tick",
+		KeyEndTimingData:     "tock",
 		KeyTotalSamples:      "samples",
 		KeyDeviceTemperature: "device_temperature",
+		KeyQuantize:          "quantize",
+		KeyOrientationIn:     "orientation_in",
+		KeyOrientationOut:    "orientation_out",
+		KeyMatrix:            "matrix",
 	}
 )
 
+// RegisterKey registers the FourCC key with name as its friendly name
+// and parser as its ParserFunc, replacing any existing registration.
+// This enables custom streams, such as those from third party
+// sensors, to be decoded without changes to this package.
+// If name is empty the existing name is kept, or key is used if there
+// is none, and if parser is nil the elements Data is left as decoded.
+// Structural keys, such as SCAL, TYPE and STMP, which the decoding of
+// other elements depends on can't be registered.
+func RegisterKey(key, name string, parser ParserFunc) error {
+	if len(key) != len(Header{}.Key) {
+		return fmt.Errorf("register key %q: invalid length %d (expected 4)", key, len(key))
+	} else if _, ok := structuralKeys[key]; ok {
+		return fmt.Errorf("register key %q: structural key", key)
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	keyParsers[key] = parser
+	if name != "" {
+		keyNames[key] = name
+	}
+
+	return nil
+}
+
+// keyParser returns the ParserFunc for key, nil if it has none.
+func keyParser(key string) ParserFunc {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	return keyParsers[key]
+}
+
+// friendlyName returns the friendly name of key.
 func friendlyName(key string) string {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
 	if f := keyNames[key]; f != "" {
 		return f
 	}
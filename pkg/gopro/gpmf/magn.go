@@ -17,6 +17,7 @@ func (d MagnetometerData) offsets(start, end time.Duration) {
 }
 
 // Magnetometer represents camera pointing direction.
+// Axes are the same as Accel.
 type Magnetometer struct {
 	X      float64
 	Y      float64
@@ -25,11 +26,12 @@ type Magnetometer struct {
 }
 
 func parseMagnetometer(e *Element) error {
-	return floatType[MagnetometerData](e, 3, func(vals []float64) Magnetometer {
+	e.initMetadata()
+	return imuType[MagnetometerData](e, func(v [3]float64) Magnetometer {
 		return Magnetometer{
-			Z: vals[0],
-			X: vals[1],
-			Y: vals[2],
+			X: v[0],
+			Y: v[1],
+			Z: v[2],
 		}
 	})
 }
@@ -1,7 +1,7 @@
 package gpmf
 
 func parseMetadata(e *Element) error {
-	e.parent.Metadata[e.friendlyName()] = e.Data
+	e.parent.Metadata[e.FriendlyName()] = e.Data
 
 	return nil
 }
@@ -1,6 +1,9 @@
 package gpmf
 
 import (
+	"fmt"
+	"math"
+	"reflect"
 	"time"
 )
 
@@ -18,24 +21,190 @@ func offsets[S ~[]E, E any](start, end time.Duration, s S, fn setOffset) {
 	}
 }
 
+// streamTiming tracks the timing of a single stream across payloads.
+type streamTiming struct {
+	// total is the number of samples seen before the current payload.
+	total float64
+
+	// firstStamp is the first STMP seen in microseconds.
+	firstStamp float64
+
+	// firstTotal is the total at firstStamp.
+	firstTotal float64
+
+	// stamped is true if firstStamp is valid.
+	stamped bool
+
+	// countStart is the start of the first payload with a TSMP.
+	countStart time.Duration
+
+	// countTotal is the total at countStart.
+	countTotal float64
+
+	// counted is true if countStart is valid.
+	counted bool
+}
+
+// timing tracks stream timing across the payloads of a single decode
+// so that per sample offsets can be calculated from STMP, TSMP and
+// TICK / TOCK when available.
+type timing struct {
+	streams map[string]*streamTiming
+
+	// offset is the start of the current chapter.
+	offset time.Duration
+
+	// chapter is the index of the current chapter.
+	chapter int
+
+	// base is the STMP, in microseconds, which maps to offset zero.
+	base    float64
+	hasBase bool
+
+	// tickBase is the TICK, in milliseconds, which maps to offset zero.
+	tickBase    float64
+	hasTickBase bool
+}
+
+// newTiming returns a new timing.
+func newTiming() *timing {
+	return &timing{streams: make(map[string]*streamTiming)}
+}
+
 // offsetWalker traverses Elements and sets their time offset.
 type offsetWalker struct {
 	start, end time.Duration
+	timing     *timing
 }
 
-// newOffsetWalker creates a new offsetWalker.
-func newOffsetWalker(start, end uint64, units time.Duration) *offsetWalker {
+// newOffsetWalker creates a new offsetWalker for a chunk which starts
+// at start and ends at end in units, using t to track stream timing.
+func newOffsetWalker(start, end uint64, units time.Duration, t *timing) *offsetWalker {
+	if t == nil {
+		t = newTiming()
+	}
+
 	return &offsetWalker{
-		start: time.Duration(start) * units, //nolint: gosec
-		end:   time.Duration(end) * units,   //nolint: gosec
+		start:  t.offset + time.Duration(start)*units, //nolint: gosec
+		end:    t.offset + time.Duration(end)*units,   //nolint: gosec
+		timing: t,
 	}
 }
 
 // walk is WalkFunc which sets offsets.
 func (o *offsetWalker) walk(e *Element) error {
-	if v, ok := e.Data.(offseter); ok {
-		v.offsets(o.start, o.end)
+	switch v := e.Data.(type) {
+	case GPSData:
+		start, end := o.span(e, dataLen(e.Data))
+		v.offsets(start, end)
+		if utc, ok := e.Metadata[friendlyName(KeyGPSTime)].(time.Time); ok {
+			v.times(utc, start)
+		}
+	case offseter:
+		v.offsets(o.span(e, dataLen(e.Data)))
+	case Offsetter:
+		v.SetOffsets(o.span(e, dataLen(e.Data)))
 	}
 
 	return nil
 }
+
+// dataLen returns the number of samples in data, 0 if it isn't a slice.
+func dataLen(data any) int {
+	if v := reflect.ValueOf(data); v.Kind() == reflect.Slice {
+		return v.Len()
+	}
+
+	return 0
+}
+
+// span returns the start and end of the n samples of e.
+//
+// If the stream has STMP timestamps they are used for the start with
+// the end determined by the effective sample rate between timestamps.
+// Otherwise TICK and TOCK are used if present, then TSMP is used to
+// determine the effective sample rate since the first chunk with TSMP,
+// with the samples spaced at that rate from the start of the chunk but
+// not beyond its end. Otherwise the samples are evenly spaced across
+// the chunk.
+// The effective sample rate, in Hz, is stored in the parent metadata
+// as sample_rate.
+func (o *offsetWalker) span(e *Element, n int) (time.Duration, time.Duration) {
+	start, end := o.start, o.end
+	if n == 0 || e.parent == nil {
+		return start, end
+	}
+
+	id, _ := e.lookup(friendlyName(KeyDeviceID))
+	key := fmt.Sprintf("%v/%s", id, e.Header.FourCC())
+	s, ok := o.timing.streams[key]
+	if !ok {
+		s = &streamTiming{}
+		o.timing.streams[key] = s
+	}
+
+	count := float64(n)
+	before := s.total
+	if v, ok := e.lookupFloat(friendlyName(KeyTotalSamples)); ok && v >= count {
+		before = v - count
+	}
+	s.total = before + count
+
+	rate := count / (end - start).Seconds()
+	stamp, hasStamp := e.lookupFloat(friendlyName(KeyTimeStamp))
+	tick, hasTick := e.lookupFloat(friendlyName(KeyBeginTimingData))
+	tock, hasTock := e.lookupFloat(friendlyName(KeyEndTimingData))
+	_, hasTotal := e.lookupFloat(friendlyName(KeyTotalSamples))
+	switch {
+	case hasStamp:
+		if !o.timing.hasBase {
+			o.timing.base = stamp - float64(start.Microseconds())
+			o.timing.hasBase = true
+		}
+
+		if !s.stamped {
+			s.firstStamp, s.firstTotal, s.stamped = stamp, before, true
+		} else if elapsed := stamp - s.firstStamp; elapsed > 0 && before > s.firstTotal {
+			rate = (before - s.firstTotal) / elapsed * 1e6
+		}
+
+		start = micros(stamp - o.timing.base)
+		end = start + seconds(count/rate)
+	case hasTick && hasTock && tock > tick:
+		if !o.timing.hasTickBase {
+			o.timing.tickBase = tick - float64(start.Milliseconds())
+			o.timing.hasTickBase = true
+		}
+
+		start = micros((tick - o.timing.tickBase) * 1e3)
+		end = micros((tock - o.timing.tickBase) * 1e3)
+		rate = count / (end - start).Seconds()
+	case hasTotal:
+		if !s.counted {
+			s.countStart, s.countTotal, s.counted = start, before, true
+		} else if elapsed := start - s.countStart; elapsed > 0 && before > s.countTotal {
+			// Limit to the chunk so offsets don't overlap the next.
+			rate = (before - s.countTotal) / elapsed.Seconds()
+			end = min(start+seconds(count/rate), end)
+		}
+	}
+
+	if !math.IsInf(rate, 0) && !math.IsNaN(rate) {
+		e.parent.Metadata[metaSampleRate] = rate
+	}
+
+	return start, end
+}
+
+// metaSampleRate is the metadata name of the effective sample rate.
+const metaSampleRate = "sample_rate"
+
+// micros returns v microseconds as a Duration.
+func micros(v float64) time.Duration {
+	return time.Duration(math.Round(v)) * time.Microsecond
+}
+
+// seconds returns v seconds as a Duration.
+func seconds(v float64) time.Duration {
+	return time.Duration(math.Round(v * float64(time.Second)))
+}
@@ -0,0 +1,98 @@
+package gpmf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetWalker(t *testing.T) {
+	// accel returns a stream containing n accel samples preceded by meta.
+	accel := func(t *testing.T, n int, meta ...[]byte) []*Element {
+		t.Helper()
+		data := bytes.Join(append(meta, klv(KeyAccel, Int16, 6, uint16(n), make([]byte, n*6))), nil) //nolint: gosec
+		strm := klv(KeyStream, Nested, 1, uint16(len(data)), data)
+		res, err := NewReader().Read(bytes.NewReader(strm))
+		require.NoError(t, err)
+		return res
+	}
+
+	stmp := func(v uint64) []byte { return klv(KeyTimeStamp, Uint64, 8, 1, be(t, v)) }
+	tsmp := func(v uint32) []byte { return klv(KeyTotalSamples, Uint32, 4, 1, be(t, v)) }
+	tick := func(v uint32) []byte { return klv(KeyBeginTimingData, Uint32, 4, 1, be(t, v)) }
+	tock := func(v uint32) []byte { return klv(KeyEndTimingData, Uint32, 4, 1, be(t, v)) }
+
+	type payload struct {
+		elems      []*Element
+		start, end uint64
+		first      time.Duration
+		last       time.Duration
+		rate       float64
+	}
+
+	tests := []struct {
+		name     string
+		payloads []payload
+	}{
+		{
+			name: "linear",
+			payloads: []payload{
+				{elems: accel(t, 4), start: 0, end: 1000, first: 0, last: 750 * time.Millisecond, rate: 4},
+				{elems: accel(t, 5), start: 1000, end: 2000, first: time.Second, last: 1800 * time.Millisecond, rate: 5},
+			},
+		},
+		{
+			name: "stmp",
+			payloads: []payload{
+				// Base is taken from the first stamp so the first payload is
+				// positioned at the chunk start using the chunk rate.
+				{elems: accel(t, 4, stmp(5_000_000), tsmp(4)), start: 0, end: 1000, first: 0, last: 750 * time.Millisecond, rate: 4},
+				// Camera delivered samples late, 4 samples in 1.2s.
+				{elems: accel(t, 4, stmp(6_200_000), tsmp(8)), start: 1000, end: 2000, first: 1200 * time.Millisecond, last: 2100 * time.Millisecond, rate: 4 / 1.2},
+				// Effective rate is now 8 samples in 2.5s.
+				{elems: accel(t, 8, stmp(7_500_000), tsmp(16)), start: 2000, end: 3000, first: 2500 * time.Millisecond, last: 2500*time.Millisecond + 7*312500*time.Microsecond, rate: 3.2},
+			},
+		},
+		{
+			name: "tsmp-dropped",
+			payloads: []payload{
+				{elems: accel(t, 4, tsmp(4)), start: 0, end: 1000, first: 0, last: 750 * time.Millisecond, rate: 4},
+				// 4 samples were dropped so the effective rate is 8Hz.
+				{elems: accel(t, 4, tsmp(12)), start: 1000, end: 2000, first: time.Second, last: 1375 * time.Millisecond, rate: 8},
+			},
+		},
+		{
+			name: "tsmp-late-start",
+			payloads: []payload{
+				// Samples before the first chunk with TSMP don't affect the rate.
+				{elems: accel(t, 4, tsmp(104)), start: 5000, end: 6000, first: 5 * time.Second, last: 5750 * time.Millisecond, rate: 4},
+				// 4Hz since the first chunk so 8 samples are limited to the chunk.
+				{elems: accel(t, 8, tsmp(112)), start: 6000, end: 7000, first: 6 * time.Second, last: 6875 * time.Millisecond, rate: 4},
+			},
+		},
+		{
+			name: "tick-tock",
+			payloads: []payload{
+				{elems: accel(t, 4, tick(10_000), tock(11_000)), start: 0, end: 1000, first: 0, last: 750 * time.Millisecond, rate: 4},
+				{elems: accel(t, 4, tick(11_000), tock(13_000)), start: 1000, end: 2000, first: time.Second, last: 2500 * time.Millisecond, rate: 2},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tm := newTiming()
+			for i, p := range tc.payloads {
+				require.NoError(t, Walk(p.elems, newOffsetWalker(p.start, p.end, time.Millisecond, tm).walk))
+				e := p.elems[0].Nested[len(p.elems[0].Nested)-1]
+				d, ok := e.Data.(AccelData)
+				require.True(t, ok)
+				require.Equal(t, p.first, d[0].Offset, "payload %d first", i)
+				require.Equal(t, p.last, d[len(d)-1].Offset, "payload %d last", i)
+				require.InDelta(t, p.rate, e.Metadata[metaSampleRate], 1e-9, "payload %d rate", i)
+			}
+		})
+	}
+}
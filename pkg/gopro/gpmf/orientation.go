@@ -0,0 +1,87 @@
+package gpmf
+
+import (
+	"math"
+	"time"
+)
+
+// OrientationData represents orientation data as used by
+// CORI (camera orientation) and IORI (image orientation).
+type OrientationData []Quaternion
+
+// offsets implements offseter.
+func (d OrientationData) offsets(start, end time.Duration) {
+	offsets(start, end, d, func(i int, val time.Duration) {
+		v := d[i]
+		v.Offset = val
+		d[i] = v
+	})
+}
+
+// Quaternion represents an orientation relative to the start of capture.
+type Quaternion struct {
+	W      float64
+	X      float64
+	Y      float64
+	Z      float64
+	Offset time.Duration
+}
+
+// Euler represents an orientation as Euler angles in radians.
+type Euler struct {
+	Roll  float64
+	Pitch float64
+	Yaw   float64
+}
+
+// Degrees returns e converted to degrees.
+func (e Euler) Degrees() Euler {
+	return Euler{
+		Roll:  e.Roll * 180 / math.Pi,
+		Pitch: e.Pitch * 180 / math.Pi,
+		Yaw:   e.Yaw * 180 / math.Pi,
+	}
+}
+
+// Normalize returns q scaled to unit length.
+func (q Quaternion) Normalize() Quaternion {
+	n := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	if n == 0 {
+		return q
+	}
+
+	q.W /= n
+	q.X /= n
+	q.Y /= n
+	q.Z /= n
+
+	return q
+}
+
+// Euler returns q as Euler angles using the aerospace (Z-Y-X) sequence
+// where roll is about the X axis, pitch about the Y axis and yaw about
+// the Z axis.
+func (q Quaternion) Euler() Euler {
+	q = q.Normalize()
+
+	// Clamp to avoid NaN due to rounding at +/-90 degrees pitch.
+	sinp := math.Max(-1, math.Min(1, 2*(q.W*q.Y-q.Z*q.X)))
+
+	return Euler{
+		Roll:  math.Atan2(2*(q.W*q.X+q.Y*q.Z), 1-2*(q.X*q.X+q.Y*q.Y)),
+		Pitch: math.Asin(sinp),
+		Yaw:   math.Atan2(2*(q.W*q.Z+q.X*q.Y), 1-2*(q.Y*q.Y+q.Z*q.Z)),
+	}
+}
+
+func parseOrientation(e *Element) error {
+	e.initMetadata()
+	return floatType[OrientationData](e, 4, func(vals []float64) Quaternion {
+		return Quaternion{
+			W: vals[0],
+			X: vals[1],
+			Y: vals[2],
+			Z: vals[3],
+		}
+	})
+}
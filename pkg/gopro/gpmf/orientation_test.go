@@ -0,0 +1,84 @@
+package gpmf
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuaternionEuler(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        Quaternion
+		expected Euler
+	}{
+		{
+			name: "identity",
+			q:    Quaternion{W: 1},
+		},
+		{
+			name:     "roll-90",
+			q:        Quaternion{W: math.Cos(math.Pi / 4), X: math.Sin(math.Pi / 4)},
+			expected: Euler{Roll: 90},
+		},
+		{
+			name:     "pitch-30",
+			q:        Quaternion{W: math.Cos(math.Pi / 12), Y: math.Sin(math.Pi / 12)},
+			expected: Euler{Pitch: 30},
+		},
+		{
+			name:     "yaw-minus-45",
+			q:        Quaternion{W: math.Cos(-math.Pi / 8), Z: math.Sin(-math.Pi / 8)},
+			expected: Euler{Yaw: -45},
+		},
+		{
+			name:     "unnormalized",
+			q:        Quaternion{W: 2 * math.Cos(math.Pi/4), X: 2 * math.Sin(math.Pi/4)},
+			expected: Euler{Roll: 90},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := tc.q.Euler().Degrees()
+			require.InDelta(t, tc.expected.Roll, e.Roll, 1e-9)
+			require.InDelta(t, tc.expected.Pitch, e.Pitch, 1e-9)
+			require.InDelta(t, tc.expected.Yaw, e.Yaw, 1e-9)
+		})
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	scal := klv(KeyScale, Int16, 2, 1, be(t, int16(32767)))
+	cori := klv(KeyCameraOrientation, Int16, 8, 2, be(t,
+		int16(32767), int16(0), int16(0), int16(0),
+		int16(23170), int16(23170), int16(0), int16(0),
+	))
+	grav := klv(KeyGavityVector, Int16, 6, 2, be(t,
+		int16(0), int16(32767), int16(0),
+		int16(0), int16(0), int16(-32767),
+	))
+	data := bytes.Join([][]byte{scal, cori, scal, grav}, nil)
+	strm := klv(KeyStream, Nested, 1, uint16(len(data)), data)
+
+	res, err := NewReader().Read(bytes.NewReader(strm))
+	require.NoError(t, err)
+	require.NoError(t, Walk(res, newOffsetWalker(0, 1000, time.Millisecond, nil).walk))
+
+	o, ok := res[0].Nested[1].Data.(OrientationData)
+	require.True(t, ok)
+	require.Len(t, o, 2)
+	require.Equal(t, Quaternion{W: 1}, o[0])
+	require.Equal(t, 500*time.Millisecond, o[1].Offset)
+	require.InDelta(t, 90, o[1].Euler().Degrees().Roll, 0.01)
+
+	g, ok := res[0].Nested[3].Data.(GravityData)
+	require.True(t, ok)
+	require.Equal(t, GravityData{
+		{Y: 1},
+		{Z: -1, Offset: 500 * time.Millisecond},
+	}, g)
+}
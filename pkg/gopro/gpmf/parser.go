@@ -0,0 +1,56 @@
+package gpmf
+
+import (
+	"time"
+)
+
+// The following are helpers for use by custom ParserFuncs registered
+// with RegisterKey.
+
+// Parent returns the parent of e, nil if e is a top level element.
+func (e *Element) Parent() *Element {
+	return e.parent
+}
+
+// Raw returns the raw, unformatted, data of e.
+func (e *Element) Raw() []byte {
+	return e.raw
+}
+
+// StoreMetadata stores the Data of e as sticky metadata in its parent
+// using its friendly name, so that it applies to the elements which
+// follow it in the same stream.
+func (e *Element) StoreMetadata() error {
+	return parseMetadata(e)
+}
+
+// InheritMetadata sets the Metadata of e to the metadata of its
+// parents, such as units and device details.
+func (e *Element) InheritMetadata() {
+	e.initMetadata()
+}
+
+// LookupMetadata returns the metadata value for the friendly name from
+// e or its closest ancestor which has it.
+func (e *Element) LookupMetadata(name string) (any, bool) {
+	return e.lookup(name)
+}
+
+// Floats returns the Data of e converted to a []float64.
+func (e *Element) Floats() ([]float64, error) {
+	return floatSlice(e.Data)
+}
+
+// ParseFloats sets the Data of e to a T created by calling fn for each
+// group of size values, which is useful for converting multi-channel
+// numeric data into a slice of structs.
+func ParseFloats[T ~[]E, E any](e *Element, size int, fn func(vals []float64) E) error {
+	return floatType[T](e, size, fn)
+}
+
+// SetOffsets calls fn for each element of s with its offset when evenly
+// spaced between start and end, which is useful for implementing
+// Offsetter.
+func SetOffsets[S ~[]E, E any](start, end time.Duration, s S, fn func(idx int, offset time.Duration)) {
+	offsets(start, end, s, fn)
+}
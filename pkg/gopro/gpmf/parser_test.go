@@ -0,0 +1,87 @@
+package gpmf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pressure is an example custom sensor sample.
+type pressure struct {
+	Pressure float64
+	Humidity float64
+	Offset   time.Duration
+}
+
+// pressureData is an example custom sensor data type.
+type pressureData []pressure
+
+// SetOffsets implements Offsetter.
+func (d pressureData) SetOffsets(start, end time.Duration) {
+	SetOffsets(start, end, d, func(i int, offset time.Duration) {
+		d[i].Offset = offset
+	})
+}
+
+func TestRegisterKey(t *testing.T) {
+	require.Error(t, RegisterKey("XPRSS", "pressure", nil))
+	for _, key := range []string{KeyScale, KeyTypeDef, KeyTotalSamples, KeyTimeStamp} {
+		require.Error(t, RegisterKey(key, "", nil), key)
+		require.NotNil(t, keyParser(key), key)
+		require.NotEqual(t, key, friendlyName(key), key)
+	}
+
+	const key = "XPRS"
+	require.NoError(t, RegisterKey(key, "pressure", func(e *Element) error {
+		e.InheritMetadata()
+		return ParseFloats[pressureData](e, 2, func(vals []float64) pressure {
+			return pressure{Pressure: vals[0], Humidity: vals[1]}
+		})
+	}))
+	require.NoError(t, RegisterKey("XCAL", "calibration", (*Element).StoreMetadata))
+	require.NoError(t, RegisterKey("XCAL", "", (*Element).StoreMetadata))
+	require.Equal(t, "calibration", friendlyName("XCAL"))
+	t.Cleanup(func() {
+		keysMu.Lock()
+		defer keysMu.Unlock()
+		delete(keyParsers, key)
+		delete(keyNames, key)
+		delete(keyParsers, "XCAL")
+		delete(keyNames, "XCAL")
+	})
+
+	data := bytes.Join([][]byte{
+		klv(KeyDeviceID, Uint32, 4, 1, be(t, uint32(99))),
+		klv("XCAL", Uint16, 2, 1, be(t, uint16(7))),
+		klv(KeyScale, Int16, 2, 2, be(t, int16(10), int16(100))),
+		klv(key, Int16, 4, 2, be(t, int16(10132), int16(4550), int16(10130), int16(4600))),
+	}, nil)
+	strm := klv(KeyStream, Nested, 1, uint16(len(data)), data) //nolint: gosec
+
+	res, err := NewReader().Read(bytes.NewReader(strm))
+	require.NoError(t, err)
+	require.NoError(t, Walk(res, newOffsetWalker(0, 1000, time.Millisecond, nil).walk))
+
+	e := res[0].Nested[3]
+	require.Equal(t, "pressure", e.FriendlyName())
+	require.Equal(t, res[0], e.Parent())
+	require.Len(t, e.Raw(), 8)
+	require.Equal(t, pressureData{
+		{Pressure: 1013.2, Humidity: 45.5},
+		{Pressure: 1013, Humidity: 46, Offset: 500 * time.Millisecond},
+	}, e.Data)
+
+	v, ok := e.LookupMetadata("calibration")
+	require.True(t, ok)
+	require.Equal(t, uint16(7), v)
+
+	v, ok = e.MetadataByKey(KeyDeviceID)
+	require.True(t, ok)
+	require.Equal(t, uint32(99), v)
+
+	vals, err := res[0].Nested[1].Floats()
+	require.NoError(t, err)
+	require.Equal(t, []float64{7}, vals)
+}
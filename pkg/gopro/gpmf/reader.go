@@ -6,57 +6,217 @@ import (
 	"io"
 )
 
+var (
+	// ErrLimit is returned, wrapped in a ReadError, when an element
+	// exceeds a Reader limit or the size of its parent.
+	ErrLimit = errors.New("limit exceeded")
+)
+
+// ReadError is the error returned by Reader.Read when an element
+// can't be read, recording where in the data it occurred.
+type ReadError struct {
+	// Offset is the offset of the elements header in the data.
+	Offset int64
+
+	// Key is the FourCC of the element, empty if its header
+	// couldn't be read.
+	Key string
+
+	// Depth is the nesting depth of the element, top level
+	// elements have a depth of 1.
+	Depth int
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (e *ReadError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("reader: offset %d: %v", e.Offset, e.Err)
+	}
+
+	return fmt.Sprintf("reader: %s at offset %d depth %d: %v", e.Key, e.Offset, e.Depth, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
 // Reader is a gpmf reader.
 type Reader struct {
+	mount    *transform
+	maxSize  int64
+	maxDepth int
+}
+
+// ReaderOption is an option for a Reader.
+type ReaderOption func(*Reader)
+
+// MountRotation sets the orientation, in degrees, of the camera in the
+// vehicle so that Accel, Gyro and Magnetometer data are rotated from the
+// camera frame into the vehicle frame.
+//
+// The vehicle frame is right-handed with X forward, Y left and Z up, so
+// a level vehicle at rest reads about +9.8 m/s² on Z and accelerating
+// reads positive X. The rotation is roll about X, then pitch about Y,
+// then yaw about Z, each positive counter-clockwise when looking back
+// along the axis towards the origin, which is nose down for pitch and
+// to the left for yaw.
+//
+// The camera frame, described by Accel, has +X right, +Y out of the
+// lens and +Z up, so for an upright camera facing forward its X and Y
+// axes are the vehicle's turned 90 degrees to the right. Such a camera
+// uses a yaw of -90, and one mounted upside down facing forward a roll
+// of 180 and a yaw of 90. Other mounts can be found by recording with
+// the vehicle level and at rest, choosing roll and pitch so gravity
+// moves to +Z, then briefly accelerating in a straight line and
+// choosing yaw so the acceleration moves to +X.
+// Default: no rotation, data is left in the camera frame.
+func MountRotation(roll, pitch, yaw float64) ReaderOption {
+	return func(re *Reader) {
+		t := rotation(roll, pitch, yaw)
+		re.mount = &t
+	}
+}
+
+// MaxElementSize sets the maximum size in bytes, including padding,
+// of a single element so that corrupt headers can't trigger large
+// allocations. Nested elements count the size of all their children.
+// Default: 0, no limit.
+func MaxElementSize(n int64) ReaderOption {
+	return func(re *Reader) {
+		re.maxSize = n
+	}
+}
+
+// MaxDepth sets the maximum nesting depth of elements, top level
+// elements have a depth of 1.
+// Default: 0, no limit.
+func MaxDepth(n int) ReaderOption {
+	return func(re *Reader) {
+		re.maxDepth = n
+	}
 }
 
 // NewReader returns a new Reader.
-func NewReader() *Reader {
-	return &Reader{}
+func NewReader(options ...ReaderOption) *Reader {
+	re := &Reader{}
+	for _, o := range options {
+		o(re)
+	}
+
+	return re
 }
 
 // Read reads and returns kvl Elements from v.
+// Errors reading elements are returned as a *ReadError.
 func (re *Reader) Read(r io.Reader) ([]*Element, error) {
 	e := NewElement(nil)
 	for i, v := range []byte(KeyStream) {
 		e.Header.Key[i] = v
 	}
 
-	if err := re.read(r, e); err != nil {
+	pr := &posReader{r: r}
+	if err := re.read(pr, pr, e); err != nil {
 		return nil, err
 	}
 
 	return e.Nested, nil
 }
 
-// read reads data from r into parent.
-func (re *Reader) read(r io.Reader, parent *Element) error {
+// read reads data from r into parent, using pr to track the position.
+func (re *Reader) read(r io.Reader, pr *posReader, parent *Element) error {
 	for {
 		e := NewElement(parent)
+		offset := pr.pos
 		if err := e.ReadHeader(r); err != nil {
 			if errors.Is(err, io.EOF) {
 				// This is only place where EOF is expected.
 				return nil
 			}
-			return fmt.Errorf("reader: read header: %w", err)
+			return &ReadError{Offset: offset, Depth: e.level, Err: err}
+		}
+
+		if err := re.check(r, e); err != nil {
+			return &ReadError{Offset: offset, Key: e.Header.FourCC(), Depth: e.level, Err: err}
 		}
 
 		if e.Header.Nested() {
 			// Nested elements.
 			lr := io.LimitReader(r, e.Total)
-			if err := re.read(lr, e); err != nil {
+			if err := re.read(lr, pr, e); err != nil {
 				return err
 			}
 		} else if err := e.ReadData(r); err != nil {
-			return err
+			return &ReadError{Offset: offset, Key: e.Header.FourCC(), Depth: e.level, Err: err}
 		}
 
 		if err := e.DiscardPadding(r); err != nil {
-			return err
+			return &ReadError{Offset: offset, Key: e.Header.FourCC(), Depth: e.level, Err: err}
 		}
 
 		if err := parent.Add(e); err != nil {
-			return err
+			return &ReadError{Offset: offset, Key: e.Header.FourCC(), Depth: e.level, Err: err}
+		}
+
+		re.rotate(e)
+	}
+}
+
+// check validates the header of e, read from r, against the limits.
+func (re *Reader) check(r io.Reader, e *Element) error {
+	switch {
+	case re.maxDepth > 0 && e.level > re.maxDepth:
+		return fmt.Errorf("depth %d > %d: %w", e.level, re.maxDepth, ErrLimit)
+	case re.maxSize > 0 && e.Total > re.maxSize:
+		return fmt.Errorf("size %d > %d: %w", e.Total, re.maxSize, ErrLimit)
+	}
+
+	// Nested elements must fit within their parent.
+	if lr, ok := r.(*io.LimitedReader); ok && e.Total > lr.N {
+		return fmt.Errorf("size %d > parent remaining %d: %w", e.Total, lr.N, ErrLimit)
+	}
+
+	return nil
+}
+
+// posReader is an io.Reader which tracks the number of bytes read.
+type posReader struct {
+	r   io.Reader
+	pos int64
+}
+
+// Read implements io.Reader.
+func (p *posReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.pos += int64(n)
+	return n, err
+}
+
+// rotate applies the mount rotation, if any, to IMU data in e.
+func (re *Reader) rotate(e *Element) {
+	if re.mount == nil {
+		return
+	}
+
+	t := *re.mount
+	switch d := e.Data.(type) {
+	case AccelData:
+		for i, v := range d {
+			r := t.apply([3]float64{v.X, v.Y, v.Z})
+			d[i].X, d[i].Y, d[i].Z = r[0], r[1], r[2]
+		}
+	case GyroData:
+		for i, v := range d {
+			r := t.apply([3]float64{v.X, v.Y, v.Z})
+			d[i].X, d[i].Y, d[i].Z = r[0], r[1], r[2]
+		}
+	case MagnetometerData:
+		for i, v := range d {
+			r := t.apply([3]float64{v.X, v.Y, v.Z})
+			d[i].X, d[i].Y, d[i].Z = r[0], r[1], r[2]
 		}
 	}
 }
@@ -2,6 +2,7 @@ package gpmf
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"testing"
@@ -87,3 +88,78 @@ func TestReader(t *testing.T) {
 		})
 	}
 }
+
+func TestReaderLimits(t *testing.T) {
+	// DEVC containing DVID and DVNM.
+	nested := []byte{
+		0x44, 0x45, 0x56, 0x43, 0x00, 0x04, 0x00, 0x07,
+		0x44, 0x56, 0x49, 0x44, 0x4c, 0x04, 0x00, 0x01,
+		0x00, 0x00, 0x10, 0x01, 0x44, 0x56, 0x4e, 0x4d,
+		0x63, 0x01, 0x00, 0x06, 0x43, 0x61, 0x6d, 0x65,
+		0x72, 0x61, 0x00, 0x00,
+	}
+
+	// DEVC with a count too small for its DVID.
+	short := bytes.Clone(nested)
+	short[7] = 0x02
+
+	tests := []struct {
+		name    string
+		options []ReaderOption
+		data    []byte
+		limit   bool
+		key     string
+		offset  int64
+		depth   int
+	}{
+		{
+			name:    "max-depth",
+			options: []ReaderOption{MaxDepth(1)},
+			data:    nested,
+			limit:   true,
+			key:     "DVID",
+			offset:  8,
+			depth:   2,
+		},
+		{
+			name:    "max-size",
+			options: []ReaderOption{MaxElementSize(16)},
+			data:    nested,
+			limit:   true,
+			key:     "DEVC",
+			depth:   1,
+		},
+		{
+			name:   "exceeds-parent",
+			data:   short,
+			limit:  true,
+			key:    "DVID",
+			offset: 8,
+			depth:  2,
+		},
+		{
+			name:   "truncated",
+			data:   nested[:22],
+			offset: 20,
+			depth:  2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewReader(tc.options...).Read(bytes.NewReader(tc.data))
+			var re *ReadError
+			require.ErrorAs(t, err, &re)
+			require.Equal(t, tc.limit, errors.Is(err, ErrLimit))
+			require.Equal(t, tc.key, re.Key)
+			require.Equal(t, tc.offset, re.Offset)
+			require.Equal(t, tc.depth, re.Depth)
+		})
+	}
+
+	t.Run("within", func(t *testing.T) {
+		data, err := NewReader(MaxDepth(2), MaxElementSize(28)).Read(bytes.NewReader(nested))
+		require.NoError(t, err)
+		require.Len(t, data, 1)
+	})
+}
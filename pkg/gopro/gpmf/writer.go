@@ -0,0 +1,201 @@
+package gpmf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Writer is a gpmf writer.
+type Writer struct {
+}
+
+// NewWriter returns a new Writer.
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// Write writes elems to w as kvl encoded data.
+//
+// Elements read by a Reader are written using their original data,
+// so unmodified elements round trip exactly, changes to their Data
+// are only written if made with Element.SetData. Elements without data
+// from a Reader, for example ones created to generate synthetic
+// telemetry, are encoded from Data according to their Header.Type
+// with Header.Size defaulting to the size of the type and Header.Count
+// calculated from the encoded data. Nested elements always have their
+// size calculated from their Nested elements. Compressed elements are
+// decompressed by the Reader so are written back uncompressed.
+func (wr *Writer) Write(w io.Writer, elems []*Element) error {
+	for _, e := range elems {
+		buf, err := wr.encode(e)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(buf); err != nil {
+			return fmt.Errorf("writer: write: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// encode returns the klv encoding of e including padding.
+func (wr *Writer) encode(e *Element) ([]byte, error) {
+	h := e.Header
+	var data []byte
+	switch {
+	case h.Nested():
+		var buf bytes.Buffer
+		if err := wr.Write(&buf, e.Nested); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+		h.Size = 1
+		if len(data) > math.MaxUint16 {
+			// Nested data is aligned so a larger size can always be used.
+			h.Size = alignment
+		}
+	case e.raw != nil:
+		data = e.raw
+	default:
+		var err error
+		if data, err = encodeData(h, e.Data); err != nil {
+			return nil, fmt.Errorf("writer: key %q: %w", h.FourCC(), err)
+		}
+	}
+
+	if h.Size == 0 {
+		h.Size = typeSize(h.Type)
+	}
+
+	if len(data)%int(h.Size) != 0 {
+		return nil, fmt.Errorf("writer: key %q: data length %d not a multiple of size %d", h.FourCC(), len(data), h.Size)
+	}
+
+	count := len(data) / int(h.Size)
+	if count > math.MaxUint16 {
+		return nil, fmt.Errorf("writer: key %q: count %d too large", h.FourCC(), count)
+	}
+	h.Count = uint16(count)
+
+	var buf bytes.Buffer
+	buf.Grow(8 + len(data) + alignment)
+	if err := binary.Write(&buf, byteOrder, h); err != nil {
+		return nil, fmt.Errorf("writer: key %q: header: %w", h.FourCC(), err)
+	}
+
+	buf.Write(data)
+	if pad := buf.Len() % alignment; pad != 0 {
+		buf.Write(make([]byte, alignment-pad))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// typeSize returns the size of a single value of t or 1 if t
+// has no fixed size.
+func typeSize(t Type) byte {
+	return byte(max(typeFieldSize(t), 1)) //nolint: gosec // At most 16.
+}
+
+// encodeData returns the raw encoding of data for h.
+func encodeData(h Header, data any) ([]byte, error) { //nolint: cyclop
+	var buf bytes.Buffer
+	switch v := data.(type) {
+	case nil:
+		return []byte{}, nil
+	case string:
+		buf.WriteString(v)
+	case []string:
+		size := int(h.Size)
+		if size == 0 {
+			size = int(typeSize(h.Type))
+		}
+		for _, s := range v {
+			if len(s) > size {
+				return nil, fmt.Errorf("string %q longer than size %d", s, size)
+			}
+			buf.WriteString(s)
+			buf.Write(make([]byte, size-len(s)))
+		}
+	case time.Time:
+		buf.WriteString(v.UTC().Format(dateFormat))
+	case []time.Time:
+		for _, t := range v {
+			buf.WriteString(t.UTC().Format(dateFormat))
+		}
+	case Scale:
+		return encodeFloats(h.Type, v)
+	case []float64:
+		if h.Type != Float64 {
+			return encodeFloats(h.Type, v)
+		}
+		return encodeBinary(v)
+	case int8, []int8, uint8, []uint8,
+		int16, []int16, uint16, []uint16,
+		int32, []int32, uint32, []uint32,
+		int64, []int64, uint64, []uint64,
+		float32, []float32, float64,
+		Int16_16, []Int16_16, Int32_32, []Int32_32:
+		return encodeBinary(v)
+	default:
+		return nil, fmt.Errorf("unsupported data type %T", data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeBinary returns the big endian encoding of v.
+func encodeBinary(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, byteOrder, v); err != nil {
+		return nil, fmt.Errorf("encode %T: %w", v, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeFloats returns the encoding of vals converted to t.
+func encodeFloats(t Type, vals []float64) ([]byte, error) {
+	var v any
+	switch t { //nolint: exhaustive
+	case Int8:
+		v = convertFloats[int8](vals)
+	case Uint8:
+		v = convertFloats[uint8](vals)
+	case Int16:
+		v = convertFloats[int16](vals)
+	case Uint16:
+		v = convertFloats[uint16](vals)
+	case Int32:
+		v = convertFloats[int32](vals)
+	case Uint32:
+		v = convertFloats[uint32](vals)
+	case Int64:
+		v = convertFloats[int64](vals)
+	case Uint64:
+		v = convertFloats[uint64](vals)
+	case Float32:
+		v = convertFloats[float32](vals)
+	case Float64:
+		v = vals
+	default:
+		return nil, fmt.Errorf("unsupported float conversion to %s", t)
+	}
+
+	return encodeBinary(v)
+}
+
+// convertFloats returns vals converted to N.
+func convertFloats[N number](vals []float64) []N {
+	r := make([]N, len(vals))
+	for i, v := range vals {
+		r[i] = N(v)
+	}
+	return r
+}
@@ -0,0 +1,138 @@
+package gpmf
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	tests := []string{
+		"hero5",
+		"fusion",
+		"hero6",
+		"hero6-multi-chunk",
+	}
+
+	for _, tc := range tests {
+		t.Run(tc, func(t *testing.T) {
+			expected, err := os.ReadFile("../../../test/" + tc + ".raw")
+			require.NoError(t, err)
+
+			data, err := NewReader().Read(bytes.NewReader(expected))
+			require.NoError(t, err)
+
+			// Padding is written as zeros but isn't always zero in the
+			// original, so compare the length and the decoded data.
+			var buf bytes.Buffer
+			require.NoError(t, NewWriter().Write(&buf, data))
+			require.Len(t, buf.Bytes(), len(expected))
+
+			written, err := NewReader().Read(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+
+			e, err := json.Marshal(data)
+			require.NoError(t, err)
+			w, err := json.Marshal(written)
+			require.NoError(t, err)
+			require.JSONEq(t, string(e), string(w))
+
+			var buf2 bytes.Buffer
+			require.NoError(t, NewWriter().Write(&buf2, written))
+			require.Equal(t, buf.Bytes(), buf2.Bytes())
+		})
+	}
+}
+
+func TestWriterSetData(t *testing.T) {
+	raw, err := os.ReadFile("../../../test/hero6.raw")
+	require.NoError(t, err)
+
+	data, err := NewReader().Read(bytes.NewReader(raw))
+	require.NoError(t, err)
+
+	// Without SetData changes to Data are ignored.
+	name := func(elems []*Element) *Element {
+		var found *Element
+		Walk(elems, func(e *Element) error { //nolint: errcheck // Never returns an error.
+			if found == nil && e.Header.FourCC() == KeyDeviceName {
+				found = e
+			}
+			return nil
+		})
+		require.NotNil(t, found)
+		return found
+	}
+	e := name(data)
+	orig := e.Data
+	e.Data = "Anon"
+	var buf bytes.Buffer
+	require.NoError(t, NewWriter().Write(&buf, data))
+	require.Equal(t, orig, name(readElements(t, buf.Bytes())).Data)
+
+	e.SetData("Anon")
+	buf.Reset()
+	require.NoError(t, NewWriter().Write(&buf, data))
+	require.Contains(t, buf.String(), KeyDeviceName+"c\x01\x00\x04Anon")
+	require.NotContains(t, buf.String(), orig)
+	require.Equal(t, "Anon", name(readElements(t, buf.Bytes())).Data)
+}
+
+// readElements reads the elements from raw.
+func readElements(t *testing.T, raw []byte) []*Element {
+	t.Helper()
+	data, err := NewReader().Read(bytes.NewReader(raw))
+	require.NoError(t, err)
+	return data
+}
+
+func TestWriterSynthetic(t *testing.T) {
+	key := func(s string) [4]byte {
+		var k [4]byte
+		copy(k[:], s)
+		return k
+	}
+
+	gpsTime := time.Date(2022, 6, 7, 11, 0, 56, 500000000, time.UTC)
+	devc := &Element{Header: Header{Key: key(KeyDevice), Type: Nested}}
+	strm := &Element{Header: Header{Key: key(KeyStream), Type: Nested}}
+	devc.Nested = []*Element{
+		{Header: Header{Key: key(KeyDeviceID), Type: Uint32}, Data: uint32(1)},
+		{Header: Header{Key: key(KeyDeviceName), Type: String}, Data: "Synthetic"},
+		strm,
+	}
+	strm.Nested = []*Element{
+		{Header: Header{Key: key(KeyStreamName), Type: String}, Data: "GPS"},
+		{Header: Header{Key: key(KeyDisplayUnits), Type: String, Size: 3}, Data: []string{"deg", "deg", "m", "m/s", "m/s"}},
+		{Header: Header{Key: key(KeyGPSTime), Type: Date}, Data: gpsTime},
+		{Header: Header{Key: key(KeyScale), Type: Int32}, Data: Scale{1e7, 1e7, 1000, 1000, 100}},
+		{Header: Header{Key: key(KeyGPS), Type: Int32, Size: 20}, Data: []int32{
+			508579280, -7526640, 51000, 20000, 2000,
+			508579390, -7525230, 52000, 21000, 2100,
+		}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewWriter().Write(&buf, []*Element{devc}))
+	require.Zero(t, buf.Len()%alignment)
+
+	data, err := NewReader().Read(&buf)
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	require.Len(t, data[0].Nested, 3)
+	require.Equal(t, "Synthetic", data[0].Nested[1].Data)
+
+	s := data[0].Nested[2]
+	require.Len(t, s.Nested, 5)
+	require.Equal(t, gpsTime, s.Nested[2].Data)
+	gps, ok := s.Nested[4].Data.(GPSData)
+	require.True(t, ok)
+	require.Equal(t, GPSData{
+		{Latitude: 50.857928, Longitude: -0.752664, Altitude: 51, Speed: 20, Speed3D: 20},
+		{Latitude: 50.857939, Longitude: -0.752523, Altitude: 52, Speed: 21, Speed3D: 21},
+	}, gps)
+}
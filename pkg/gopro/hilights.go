@@ -0,0 +1,112 @@
+package gopro
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf"
+)
+
+// hiLights returns the HiLight tags of s read from baseFS.
+func (p *Processor) hiLights(s *FileSet) (*gpmf.HiLights, error) {
+	chapters := make([]io.ReadSeeker, 0, len(s.Chapters))
+	for _, c := range s.Chapters {
+		f, err := baseFS.Open(filepath.Join(p.cfg.SourceDir, c.Name))
+		if err != nil {
+			return nil, fmt.Errorf("hilights: %w", err)
+		}
+		defer f.Close() //nolint: errcheck
+
+		rs, ok := f.(io.ReadSeeker)
+		if !ok {
+			return nil, fmt.Errorf("hilights: %q not seekable", c.Name)
+		}
+
+		chapters = append(chapters, rs)
+	}
+
+	hl, err := gpmf.DecodeHiLights(chapters...)
+	if err != nil {
+		return nil, fmt.Errorf("hilights: %w", err)
+	}
+
+	return hl, nil
+}
+
+// writeChapters writes ffmpeg metadata to w with a chapter starting
+// at each HiLight tag in hl, preceded by a start chapter if the first
+// tag isn't at the start of the recording.
+func writeChapters(w io.WriteCloser, hl *gpmf.HiLights) error {
+	if _, err := fmt.Fprintln(w, ";FFMETADATA1"); err != nil {
+		return fmt.Errorf("chapter data: %w", err)
+	}
+
+	type chapter struct {
+		start time.Duration
+		title string
+	}
+
+	var chapters []chapter
+	if len(hl.Tags) == 0 || hl.Tags[0] != 0 {
+		chapters = append(chapters, chapter{title: "Start"})
+	}
+
+	for i, v := range hl.Tags {
+		chapters = append(chapters, chapter{start: v, title: fmt.Sprintf("HiLight %d", i+1)})
+	}
+
+	for i, c := range chapters {
+		end := hl.Duration
+		if i+1 < len(chapters) {
+			end = chapters[i+1].start
+		}
+
+		if _, err := fmt.Fprintf(w, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			c.start.Milliseconds(), max(end, c.start).Milliseconds(), c.title,
+		); err != nil {
+			return fmt.Errorf("chapter data: %w", err)
+		}
+	}
+
+	return w.Close()
+}
+
+// chapterArgs returns the ffmpeg args for s with the HiLight tags
+// added as chapters if configured, and a function to remove any
+// temporary files created.
+func (p *Processor) chapterArgs(s *FileSet, args []string) ([]string, func(), error) {
+	if !p.cfg.HiLightChapters {
+		return args, func() {}, nil
+	}
+
+	hl, err := p.hiLights(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(hl.Tags) == 0 {
+		return args, func() {}, nil
+	}
+
+	f, err := baseFS.CreateTemp("", "gopro-chapters")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary chapters file: %w", err)
+	}
+	cleanup := func() { baseFS.Remove(f.Name()) } //nolint: errcheck
+
+	if err := writeChapters(f, hl); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("write chapters file %q: %w", f.Name(), err)
+	}
+
+	// Add the chapters as a second input directly after the first.
+	idx := p.cfg.inputIndex + 1
+	res := make([]string, 0, len(args)+4)
+	res = append(res, args[:idx]...)
+	res = append(res, "-i", f.Name(), "-map_chapters", "1")
+	res = append(res, args[idx:]...)
+
+	return res, cleanup, nil
+}
@@ -0,0 +1,139 @@
+package gopro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+	"github.com/stevenh/tracktools/pkg/gopro/gpmf"
+	"github.com/stretchr/testify/require"
+)
+
+// testHiLightsMP4 returns a mp4 of duration ms containing the HiLight tags.
+func testHiLightsMP4(t *testing.T, ms uint32, tags ...uint32) []byte {
+	t.Helper()
+
+	var data bytes.Buffer
+	require.NoError(t, binary.Write(&data, binary.BigEndian, append([]uint32{uint32(len(tags))}, tags...))) //nolint: gosec
+
+	hdr := mp4.BoxHeader{Name: "HMMT", Size: uint64(8 + data.Len()), Hdrlen: 8}
+	hmmt, err := mp4.DecodeUnknown(hdr, 0, &data)
+	require.NoError(t, err)
+
+	udta := &mp4.UdtaBox{}
+	udta.AddChild(hmmt)
+
+	mvhd := mp4.CreateMvhd()
+	mvhd.Timescale = 1000
+	mvhd.Duration = uint64(ms)
+
+	trak := mp4.CreateEmptyTrak(1, 1000, "vide", "und")
+	trak.Mdia.Minf.Stbl.Stts.SampleCount = []uint32{1}
+	trak.Mdia.Minf.Stbl.Stts.SampleTimeDelta = []uint32{ms}
+
+	moov := mp4.NewMoovBox()
+	moov.AddChild(mvhd)
+	moov.AddChild(trak)
+	moov.AddChild(udta)
+
+	var buf bytes.Buffer
+	for _, b := range []mp4.Box{mp4.CreateFtyp(), moov} {
+		require.NoError(t, b.Encode(&buf))
+	}
+
+	return buf.Bytes()
+}
+
+func TestWriteChapters(t *testing.T) {
+	tests := []struct {
+		name     string
+		hl       *gpmf.HiLights
+		expected string
+	}{
+		{
+			name: "tags",
+			hl: &gpmf.HiLights{
+				Tags:     []time.Duration{1500 * time.Millisecond, 30 * time.Second},
+				Duration: time.Minute,
+			},
+			expected: `;FFMETADATA1
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=1500
+title=Start
+[CHAPTER]
+TIMEBASE=1/1000
+START=1500
+END=30000
+title=HiLight 1
+[CHAPTER]
+TIMEBASE=1/1000
+START=30000
+END=60000
+title=HiLight 2
+`,
+		},
+		{
+			name: "tag-at-start",
+			hl: &gpmf.HiLights{
+				Tags:     []time.Duration{0},
+				Duration: time.Minute,
+			},
+			expected: `;FFMETADATA1
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=60000
+title=HiLight 1
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &tmpFile{}
+			require.NoError(t, writeChapters(f, tc.hl))
+			require.Equal(t, tc.expected, string(f.data))
+		})
+	}
+}
+
+func TestProcessorChapterArgs(t *testing.T) {
+	tfs := newTestFS()
+	tfs.mapFS["GX010001.MP4"] = &fstest.MapFile{Data: testHiLightsMP4(t, 60000, 1500)}
+	tfs.mapFS["GX020001.MP4"] = &fstest.MapFile{Data: testHiLightsMP4(t, 30000, 250)}
+	tfs.mapFS["GX010002.MP4"] = &fstest.MapFile{Data: testHiLightsMP4(t, 30000)}
+	baseFS = tfs
+
+	args := []string{"-y", "-i", "", "-c:v", "copy"}
+	cfg := *DefaultConfig
+	cfg.Args = args
+	cfg.HiLightChapters = true
+	p, err := NewProcessor(Cfg(cfg))
+	require.NoError(t, err)
+
+	sets, err := p.fileSets()
+	require.NoError(t, err)
+	require.Len(t, sets, 2)
+
+	t.Run("tags", func(t *testing.T) {
+		res, cleanup, err := p.chapterArgs(sets["0001"], args)
+		require.NoError(t, err)
+		require.Len(t, tfs.tempFiles, 1)
+		f := tfs.tempFiles[0]
+		require.Equal(t, []string{"-y", "-i", "", "-i", f.Name(), "-map_chapters", "1", "-c:v", "copy"}, res)
+		require.Contains(t, string(f.data), "START=60250\nEND=90000\ntitle=HiLight 2\n")
+		cleanup()
+	})
+
+	t.Run("none", func(t *testing.T) {
+		res, cleanup, err := p.chapterArgs(sets["0002"], args)
+		require.NoError(t, err)
+		require.Equal(t, args, res)
+		cleanup()
+	})
+}
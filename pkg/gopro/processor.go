@@ -140,7 +140,7 @@ func (p *Processor) fileSets() (map[string]*FileSet, error) {
 
 			s, ok := sets[f.Index]
 			if !ok {
-				s = &FileSet{Number: f.Index}
+				s = &FileSet{Number: f.Index, Dir: p.cfg.SourceDir}
 				sets[f.Index] = s
 			}
 			s.Chapter(f)
@@ -245,7 +245,13 @@ func (p *Processor) processSet(s *FileSet) (string, error) {
 	// Set the input file name by index.
 	p.cfg.Args[p.cfg.inputIndex] = f.Name()
 
-	args := append(p.cfg.Args, output) //nolint: gocritic
+	args, cleanup, err := p.chapterArgs(s, p.cfg.Args)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	args = append(args, output)
 	p.log.Print("handle:", p.cfg.Binary, args)
 	if err = p.handler(p.cfg.Binary, args...); err != nil {
 		return "", err
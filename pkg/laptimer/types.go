@@ -30,6 +30,42 @@ func NewDB() *DB {
 	return &DB{Name: "LapTimer Database"}
 }
 
+// AddMarkers adds a line to the Note of each lap for each of marks
+// which occurred during the lap, consisting of label followed by the
+// time into the lap, for example "HiLight 01:23.45".
+// It returns the number of marks added.
+func (db *DB) AddMarkers(label string, marks ...time.Time) int {
+	var added int
+	for i := range db.Laps {
+		l := &db.Laps[i]
+		start := l.Start()
+		end := start.Add(time.Duration(l.LapTime))
+		for _, m := range marks {
+			if m.Before(start) || !m.Before(end) {
+				continue
+			}
+
+			if l.Note != "" {
+				l.Note += "\n"
+			}
+			l.Note += label + " " + Duration(m.Sub(start)).String()
+			added++
+		}
+	}
+
+	return added
+}
+
+// Start returns the start time of the lap, which is the date of
+// the first fix if present as Date only has second precision.
+func (l Lap) Start() time.Time {
+	if len(l.Recording.Fixes) > 0 {
+		return time.Time(l.Recording.Fixes[0].Date)
+	}
+
+	return time.Time(l.Date)
+}
+
 // Lap represents a LapTimer lap.
 type Lap struct {
 	ID               int              `xml:"index,attr"`
@@ -208,3 +208,33 @@ func TestIntermediatesXML(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, v1, v2)
 }
+
+func TestDBAddMarkers(t *testing.T) {
+	start := time.Date(2022, time.May, 31, 8, 1, 33, 800000000, time.UTC)
+	db := NewDB()
+	db.Laps = []Lap{
+		{
+			Date:    LapDate(start.Truncate(time.Second)),
+			LapTime: Duration(time.Minute),
+			Recording: Recording{
+				Fixes: []Fix{{Date: FixDate(start)}},
+			},
+		},
+		{
+			Date:    LapDate(start.Add(time.Minute)),
+			LapTime: Duration(time.Minute),
+			Note:    "wet",
+		},
+	}
+
+	added := db.AddMarkers("HiLight",
+		start.Add(-time.Second),
+		start.Add(12*time.Second+340*time.Millisecond),
+		start.Add(time.Minute+5*time.Second),
+		start.Add(time.Minute+50*time.Second),
+		start.Add(3*time.Minute),
+	)
+	require.Equal(t, 3, added)
+	require.Equal(t, "HiLight 00:12.34", db.Laps[0].Note)
+	require.Equal(t, "wet\nHiLight 00:05.00\nHiLight 00:50.00", db.Laps[1].Note)
+}